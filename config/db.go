@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,13 +13,20 @@ import (
 
 var DB *gorm.DB
 
-func InitDB() {
+var loadEnvOnce sync.Once
 
-	errs := godotenv.Load()
-	if errs != nil {
-		log.Println("Error loading .env file. Falling back to system environment variables.")
-		panic(errs)
-	}
+// LoadEnv reads .env into the environment, once per process. Without the
+// file the system environment is used as it is.
+func LoadEnv() {
+	loadEnvOnce.Do(func() {
+		if err := godotenv.Load(); err != nil {
+			log.Println("Error loading .env file. Falling back to system environment variables.")
+		}
+	})
+}
+
+func InitDB() {
+	LoadEnv()
 
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+type JudgeConfig struct {
+	Backend string // judge0, sandbox
+
+	Judge0URLs   []string // load is spread over every healthy endpoint
+	Judge0Key    string
+	Judge0Host   string
+	Judge0Header string
+
+	Judge0Timeout         time.Duration // per HTTP request
+	Judge0Retries         int           // extra attempts on transient errors
+	Judge0BreakerFailures int           // consecutive failures that open an endpoint's breaker
+	Judge0BreakerCooldown time.Duration
+	Judge0HealthInterval  time.Duration // 0 disables health checks
+
+	SandboxWorkDir       string
+	SandboxUID           int      // a dedicated unprivileged user, never root or the server's own
+	SandboxGID           int      // a dedicated unprivileged group
+	SandboxReadOnlyPaths []string // host paths mounted read-only into every run, for the toolchains
+	SandboxCgroup        string   // cgroup v2 directory that runs are measured in
+	SandboxCompileTime   int      // in milliseconds
+	SandboxCompileMemory int      // in KB
+	SandboxOutputLimit   int      // in KB
+}
+
+func LoadJudgeConfig() JudgeConfig {
+	return JudgeConfig{
+		Backend:      getEnvOrDefault("JUDGE_BACKEND", "judge0"),
+		Judge0URLs:   splitURLs(getEnvOrDefault("JUDGE0_URLS", getEnvOrDefault("JUDGE0_URL", "https://judge0-ce.p.rapidapi.com"))),
+		Judge0Key:    getEnvOrDefault("JUDGE0_API_KEY", os.Getenv("RAPIDAPI_KEY")),
+		Judge0Host:   os.Getenv("JUDGE0_HOST"), // derived from the URL for RapidAPI hosts
+		Judge0Header: getEnvOrDefault("JUDGE0_AUTH_HEADER", "x-rapidapi-key"),
+
+		Judge0Timeout:         time.Duration(getEnvAsInt("JUDGE0_TIMEOUT", 30)) * time.Second,
+		Judge0Retries:         getEnvAsInt("JUDGE0_RETRIES", 2),
+		Judge0BreakerFailures: getEnvAsInt("JUDGE0_BREAKER_FAILURES", 5),
+		Judge0BreakerCooldown: time.Duration(getEnvAsInt("JUDGE0_BREAKER_COOLDOWN", 30)) * time.Second,
+		Judge0HealthInterval:  time.Duration(getEnvAsInt("JUDGE0_HEALTH_INTERVAL", 30)) * time.Second,
+
+		SandboxWorkDir:       getEnvOrDefault("SANDBOX_WORK_DIR", os.TempDir()),
+		SandboxUID:           getEnvAsInt("SANDBOX_UID", 0),
+		SandboxGID:           getEnvAsInt("SANDBOX_GID", 0),
+		SandboxReadOnlyPaths: splitPaths(getEnvOrDefault("SANDBOX_READONLY_PATHS", "/bin,/sbin,/usr,/lib,/lib32,/lib64,/libx32,/etc/alternatives,/etc/ld.so.cache")),
+		SandboxCgroup:        getEnvOrDefault("SANDBOX_CGROUP", "/sys/fs/cgroup/contest-sandbox"),
+		SandboxCompileTime:   getEnvAsInt("SANDBOX_COMPILE_TIME_LIMIT", 10000),
+		SandboxCompileMemory: getEnvAsInt("SANDBOX_COMPILE_MEMORY_LIMIT", 1024*1024),
+		SandboxOutputLimit:   getEnvAsInt("SANDBOX_OUTPUT_LIMIT", 64*1024),
+	}
+}
+
+// splitURLs parses a comma separated list of base URLs.
+func splitURLs(value string) []string {
+	var urls []string
+	for _, u := range strings.Split(value, ",") {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// splitPaths parses a comma separated list of absolute paths.
+func splitPaths(value string) []string {
+	var paths []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func getEnvOrDefault(name string, defaultVal string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultVal
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+type QueueConfig struct {
+	Workers      int
+	Capacity     int
+	PollInterval time.Duration
+	StaleAfter   time.Duration // a judging submission older than this is requeued
+	JudgeTimeout time.Duration
+	TestWorkers  int // tests of one submission run at once
+}
+
+func LoadQueueConfig() QueueConfig {
+	return QueueConfig{
+		Workers:      getEnvAsInt("QUEUE_WORKERS", 4),
+		Capacity:     getEnvAsInt("QUEUE_CAPACITY", 1000),
+		PollInterval: time.Duration(getEnvAsInt("QUEUE_POLL_INTERVAL", 5)) * time.Second,
+		StaleAfter:   time.Duration(getEnvAsInt("QUEUE_STALE_AFTER", 600)) * time.Second,
+		JudgeTimeout: time.Duration(getEnvAsInt("QUEUE_JUDGE_TIMEOUT", 300)) * time.Second,
+		TestWorkers:  getEnvAsInt("QUEUE_TEST_WORKERS", 4),
+	}
+}
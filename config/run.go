@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+type RunConfig struct {
+	RateLimit  int // runs per user per window
+	RateWindow time.Duration
+	Timeout    time.Duration
+	MaxInput   int // in bytes
+}
+
+func LoadRunConfig() RunConfig {
+	return RunConfig{
+		RateLimit:  getEnvAsInt("RUN_RATE_LIMIT", 10),
+		RateWindow: time.Duration(getEnvAsInt("RUN_RATE_WINDOW", 60)) * time.Second,
+		Timeout:    time.Duration(getEnvAsInt("RUN_TIMEOUT", 30)) * time.Second,
+		MaxInput:   getEnvAsInt("RUN_MAX_INPUT", 1<<20),
+	}
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+type SchedulerConfig struct {
+	Interval time.Duration // between two looks at the contest table
+}
+
+func LoadSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		Interval: time.Duration(getEnvAsInt("SCHEDULER_INTERVAL", 5)) * time.Second,
+	}
+}
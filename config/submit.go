@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// SubmitConfig limits submissions per user. A zero value disables a limit.
+type SubmitConfig struct {
+	ProblemLimit  int // submissions per user and problem in ProblemWindow
+	ProblemWindow time.Duration
+	MinGap        time.Duration // between any two submissions of a user
+	ContestCap    int           // submissions per user during a contest
+}
+
+func LoadSubmitConfig() SubmitConfig {
+	return SubmitConfig{
+		ProblemLimit:  getEnvAsInt("SUBMIT_PROBLEM_LIMIT", 5),
+		ProblemWindow: time.Duration(getEnvAsInt("SUBMIT_PROBLEM_WINDOW", 60)) * time.Second,
+		MinGap:        time.Duration(getEnvAsInt("SUBMIT_MIN_GAP", 5)) * time.Second,
+		ContestCap:    getEnvAsInt("SUBMIT_CONTEST_CAP", 0),
+	}
+}
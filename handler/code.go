@@ -1,19 +1,46 @@
 package handler
 
 import (
-	"strings"
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/languages"
 	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/queue"
+	"github.com/ankush-web-eng/contest-backend/ratelimit"
 	"github.com/ankush-web-eng/contest-backend/types"
-	"github.com/ankush-web-eng/contest-backend/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// runOutputLimit bounds each stream returned by a custom run.
+const runOutputLimit = 64 * 1024
+
+var (
+	runConfig    config.RunConfig
+	submitConfig config.SubmitConfig
 )
 
 func RegisterCodeRoutes(r *gin.Engine) {
+	submitConfig = config.LoadSubmitConfig()
+	runConfig = config.LoadRunConfig()
+
 	codeRouter := r.Group("/code")
 	{
 		codeRouter.POST("/submit", submitCode)
+		codeRouter.POST("/run", runCode)
+		codeRouter.GET("/submission/:id/status", getSubmissionStatus)
+		codeRouter.GET("/submission/:id", getSubmissionResult)
+		codeRouter.POST("/admin/rejudge/submission/:id", rejudgeSubmission)
+		codeRouter.POST("/admin/rejudge/problem/:id", rejudgeProblem)
+		codeRouter.POST("/admin/rejudge/contest/:id", rejudgeContest)
 	}
 }
 
@@ -39,125 +66,340 @@ func submitCode(c *gin.Context) {
 		return
 	}
 
-	languageId, err := utils.GetLanguageId(req.Language)
+	language, err := languages.Get(req.Language)
 	if err != nil {
 		c.JSON(400, gin.H{"message": err.Error()})
 		return
 	}
 
 	var problem models.Problem
-	if err := db.Where("id = ?", req.ProblemID).First(&problem).Error; err != nil {
+	if err := db.Preload("Contest").Where("id = ?", req.ProblemID).First(&problem).Error; err != nil || (!problem.IsPublished && !user.IsAdmin) {
 		c.JSON(404, gin.H{"message": "Problem not found"})
 		return
 	}
+	if problem.ContestID != req.ContestID {
+		c.JSON(400, gin.H{"message": "Problem does not belong to this contest"})
+		return
+	}
+	if problem.Contest.Status == models.ContestPending && !user.IsAdmin {
+		c.JSON(403, gin.H{"message": "Contest has not started yet"})
+		return
+	}
 
-	var testCases []models.TestCase
-	if err := db.Where("problem_id = ?", req.ProblemID).Find(&testCases).Error; err != nil {
+	var testCases int64
+	if err := db.Model(&models.TestCase{}).Where("problem_id = ?", req.ProblemID).Count(&testCases).Error; err != nil {
 		c.JSON(500, gin.H{"message": "Error fetching test cases"})
 		return
 	}
 
-	var cases []models.TestCase
-	results := []map[string]interface{}{}
-
-	cases = testCases
-
-	if len(cases) == 0 {
+	if testCases == 0 {
 		c.JSON(400, gin.H{"message": "No test cases found for this problem"})
 		return
 	}
-	abortDueToTLE := false
 
-	for _, testCase := range cases {
-		if abortDueToTLE {
-			break
-		}
+	now := time.Now().UTC()
+	participation, virtualID, rejection, err := participationFor(db, &user, &problem.Contest, now)
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Error checking your participation"})
+		return
+	}
+	if rejection != "" {
+		c.JSON(403, gin.H{"message": rejection})
+		return
+	}
 
-		result, err := utils.SubmitCodeToJudge(req.Code, languageId, models.TestCase{
-			Input:       testCase.Input,
-			Output:      testCase.Output,
-			TimeLimit:   problem.TimeLimit,
-			MemoryLimit: problem.MemoryLimit,
-			ID:          testCase.ID,
-		})
+	submission := models.Submission{
+		UserID:                 user.ID,
+		ProblemID:              problem.ID,
+		ContestID:              problem.ContestID,
+		Language:               language.Slug,
+		Code:                   req.Code,
+		Status:                 queue.StatusQueued,
+		ParticipationType:      participation,
+		VirtualParticipationID: virtualID,
+		SubmittedAt:            now,
+	}
 
-		if err != nil {
-			c.JSON(500, gin.H{"message": "Judge error: " + err.Error()})
-			return
+	var violation *ratelimit.Violation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		violation, err = ratelimit.CheckSubmission(tx, submitConfig, user.ID, &problem, participation, now)
+		if err != nil || violation != nil {
+			return err
 		}
+		return tx.Create(&submission).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Error creating submission"})
+		return
+	}
+	if violation != nil {
+		tooManyRequests(c, violation.Message, violation.RetryAfter)
+		return
+	}
 
-		if status, ok := result["status"].(map[string]interface{}); ok {
-			if description, ok := status["description"].(string); ok && description == "Time Limit Exceeded" {
-				abortDueToTLE = true
-			}
-		}
+	queue.Enqueue(submission.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":       "Submission queued",
+		"submission_id": submission.ID,
+		"status":        submission.Status,
+	})
+}
+
+// runCode executes the code once against the sample or the caller's input
+// and returns the raw output, through the same judge and isolation as
+// submissions. No submission is stored and no statistics change; only the
+// time of the run is kept, for the rate limit.
+func runCode(c *gin.Context) {
+	var req types.RunCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"message": err.Error() + "\n Please provide valid data"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
-		stdout := result["stdout"].(string)
-		isCorrect := strings.TrimSpace(stdout) == strings.TrimSpace(testCase.Output)
-		status := "Accepted"
-		if !isCorrect {
-			status = "Wrong Answer"
+	if req.Input != nil && len(*req.Input) > runConfig.MaxInput {
+		c.JSON(400, gin.H{"message": "Input is too large!!"})
+		return
+	}
+
+	language, err := languages.Get(req.Language)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var problem models.Problem
+	if err := db.Preload("Contest").Where("id = ?", req.ProblemID).First(&problem).Error; err != nil || (!problem.IsPublished && !user.IsAdmin) {
+		c.JSON(404, gin.H{"message": "Problem not found"})
+		return
+	}
+	if problem.Contest.Status == models.ContestPending && !user.IsAdmin {
+		c.JSON(403, gin.H{"message": "Contest has not started yet"})
+		return
+	}
+	if problem.InteractorSource != "" {
+		c.JSON(400, gin.H{"message": "Custom runs are not available for interactive problems"})
+		return
+	}
+
+	var violation *ratelimit.Violation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		var err error
+		violation, err = ratelimit.CheckRun(tx, runConfig, user.ID, now)
+		if err != nil || violation != nil {
+			return err
 		}
+		return tx.Create(&models.CodeRun{UserID: user.ID, ProblemID: problem.ID, RunAt: now}).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Error checking the run limit"})
+		return
+	}
+	if violation != nil {
+		tooManyRequests(c, violation.Message, violation.RetryAfter)
+		return
+	}
+
+	input := problem.SampleInput
+	if req.Input != nil {
+		input = *req.Input
+	}
+
+	limits, err := languages.ProblemLimits(&problem, language)
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Error fetching problem limits"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), runConfig.Timeout)
+	defer cancel()
+
+	result, err := judge.GetJudge().Execute(ctx, judge.ExecutionRequest{
+		SourceCode:  req.Code,
+		Language:    languages.ToJudge(language),
+		Stdin:       input,
+		TimeLimit:   limits.TimeLimit,
+		MemoryLimit: limits.MemoryLimit * 1024,
+	})
+	if errors.Is(err, judge.ErrJudgeUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "Judge is unavailable, try again later"})
+		return
+	}
+	if err != nil {
+		log.Println("Failed to run code for user", user.ID, ":", err)
+		c.JSON(502, gin.H{"message": "Could not run the code, try again later"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":         result.Status,
+		"description":    result.Description,
+		"stdout":         judge.TruncateOutput(result.Stdout, runOutputLimit),
+		"stderr":         judge.TruncateOutput(result.Stderr, runOutputLimit),
+		"compile_output": judge.TruncateOutput(result.CompileOutput, runOutputLimit),
+		"exit_code":      result.ExitCode,
+		"time":           result.Time,
+		"memory":         result.Memory,
+	})
+}
+
+func tooManyRequests(c *gin.Context, message string, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"message": message})
+}
+
+// findOwnSubmission loads a submission that belongs to the caller, or to
+// anyone when the caller is an admin, along with the caller.
+func findOwnSubmission(c *gin.Context) (*models.Submission, *models.User, bool) {
+	sessionToken, err := c.Cookie("session_token")
+	if err != nil {
+		c.JSON(400, gin.H{"message": "Session token not found"})
+		return nil, nil, false
+	}
+
+	db := config.GetDB()
+	var user models.User
+
+	if err := db.Where("session_token = ?", sessionToken).First(&user).Error; err != nil {
+		c.JSON(401, gin.H{"message": "Unauthorized access!!"})
+		return nil, nil, false
+	}
+
+	var submission models.Submission
+	if err := db.First(&submission, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"message": "Submission not found"})
+		return nil, nil, false
+	}
 
-		results = append(results, map[string]interface{}{
-			"status":     status,
-			"testCaseId": testCase.ID,
-			"stdout":     stdout,
+	if submission.UserID != user.ID && !user.IsAdmin {
+		c.JSON(404, gin.H{"message": "Submission not found"})
+		return nil, nil, false
+	}
+
+	return &submission, &user, true
+}
+
+func getSubmissionStatus(c *gin.Context) {
+	submission, _, ok := findOwnSubmission(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"submission_id": submission.ID,
+		"status":        submission.Status,
+		"done":          submission.Status != queue.StatusQueued && submission.Status != queue.StatusJudging,
+	})
+}
+
+func getSubmissionResult(c *gin.Context) {
+	submission, user, ok := findOwnSubmission(c)
+	if !ok {
+		return
+	}
+
+	if submission.Status == queue.StatusQueued || submission.Status == queue.StatusJudging {
+		c.JSON(http.StatusAccepted, gin.H{
+			"submission_id": submission.ID,
+			"status":        submission.Status,
 		})
+		return
 	}
 
-	allPassed := true
-	for _, result := range results {
-		if result["status"] != "Accepted" {
-			allPassed = false
-			break
-		}
+	db := config.GetDB()
+	if err := db.Where("submission_id = ?", submission.ID).Order("id").Find(&submission.TestResults).Error; err != nil {
+		c.JSON(500, gin.H{"message": "Failed to fetch test results"})
+		return
+	}
+	if err := db.Where("submission_id = ?", submission.ID).Order("id").Find(&submission.History).Error; err != nil {
+		c.JSON(500, gin.H{"message": "Failed to fetch verdict history"})
+		return
 	}
 
-	overallStatus := "Accepted"
-	if !allPassed {
-		overallStatus = "Failed"
+	if user.IsAdmin {
+		c.JSON(200, gin.H{"submission": submission})
+		return
 	}
 
-	if problem.TestCasesCount > 0 {
-		tx := db.Begin() // db transaction
+	var hidden []uint
+	if err := db.Model(&models.TestCase{}).Where("problem_id = ? AND is_hidden = ?", submission.ProblemID, true).Pluck("id", &hidden).Error; err != nil {
+		c.JSON(500, gin.H{"message": "Failed to fetch test cases"})
+		return
+	}
+	c.JSON(200, gin.H{"submission": types.NewSubmissionResponse(*submission, hidden)})
+}
 
-		submission := models.Submission{
-			Status:    overallStatus,
-			Language:  req.Language,
-			ProblemID: req.ProblemID,
-			UserID:    user.ID,
-		}
-		if err := tx.Create(&submission).Error; err != nil {
-			tx.Rollback()
-			c.JSON(500, gin.H{"message": "Error creating submission"})
-			return
-		}
+func rejudgeSubmission(c *gin.Context) {
+	admin, ok := adminUser(c)
+	if !ok {
+		return
+	}
 
-		// if err := tx.Model(&problem).UpdateColumn("attempt_count", gorm.Expr("attempt_count + ?", 1)).Error; err != nil {
-		// 	tx.Rollback()
-		// 	c.JSON(500, gin.H{"message": "Error updating problem statistics"})
-		// 	return
-		// }
-
-		// if allPassed {
-		// 	if err := tx.Model(&problem).UpdateColumn("success_count", gorm.Expr("success_count + ?", 1)).Error; err != nil {
-		// 		tx.Rollback()
-		// 		c.JSON(500, gin.H{"message": "Error updating problem statistics"})
-		// 		return
-		// 	}
-		// }
-
-		if err := tx.Commit().Error; err != nil {
-			c.JSON(500, gin.H{"message": "Error committing transaction"})
-			return
-		}
+	var submission models.Submission
+	if err := config.GetDB().First(&submission, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"message": "Submission not found"})
+		return
+	}
+
+	rejudge(c, admin, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id = ?", submission.ID)
+	})
+}
+
+func rejudgeProblem(c *gin.Context) {
+	admin, ok := adminUser(c)
+	if !ok {
+		return
+	}
+
+	var problem models.Problem
+	if err := config.GetDB().First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"message": "Problem not found"})
+		return
+	}
+
+	rejudge(c, admin, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("problem_id = ?", problem.ID)
+	})
+}
+
+func rejudgeContest(c *gin.Context) {
+	admin, ok := adminUser(c)
+	if !ok {
+		return
 	}
 
-	response := gin.H{
-		"status":  overallStatus,
-		"results": results,
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"message": "Contest not found"})
+		return
+	}
+
+	problems := db.Model(&models.Problem{}).Select("id").Where("contest_id = ?", contest.ID)
+	rejudge(c, admin, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("problem_id IN (?)", problems)
+	})
+}
+
+func rejudge(c *gin.Context, admin *models.User, scope func(*gorm.DB) *gorm.DB) {
+	count, err := queue.Rejudge(scope, admin.ID)
+	if err != nil {
+		log.Println("Failed to rejudge:", err)
+		c.JSON(500, gin.H{"message": "Failed to rejudge submissions"})
+		return
 	}
 
-	c.JSON(200, response)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     "Rejudge queued",
+		"submissions": count,
+	})
 }
@@ -5,9 +5,13 @@ import (
 	"time"
 
 	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/languages"
 	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/standings"
 	"github.com/ankush-web-eng/contest-backend/types"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func RegisterContestRoutes(r *gin.Engine) {
@@ -18,6 +22,14 @@ func RegisterContestRoutes(r *gin.Engine) {
 		contestRoutes.POST("/create", createContest)
 		contestRoutes.POST("/update/problems", updateContestProblems)
 		contestRoutes.GET("/get-one/:id", getSingleContest)
+		contestRoutes.POST("/:id/register", registerForContest)
+		contestRoutes.DELETE("/:id/register", unregisterFromContest)
+		contestRoutes.POST("/:id/start", startContestWindow)
+		contestRoutes.POST("/:id/virtual", startVirtualParticipation)
+		contestRoutes.GET("/:id/standings", getStandings)
+		contestRoutes.GET("/admin/:id/resolver", getResolver)
+		contestRoutes.POST("/admin/:id/resolver/step", resolveStep)
+		contestRoutes.POST("/admin/:id/unfreeze", unfreezeStandings)
 	}
 }
 
@@ -27,12 +39,28 @@ func getSingleContest(c *gin.Context) {
 	var db = config.GetDB()
 	var contest models.Contest
 
-	if err := db.Preload("Problems.TestCases").First(&contest, contestID).Error; err != nil {
+	if err := db.Preload("Problems.TestCases").Preload("Problems.TestGroups").Preload("Problems.LanguageLimits").First(&contest, contestID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"contest": contest})
+	user := optionalUser(c)
+	if user != nil && user.IsAdmin {
+		c.JSON(http.StatusOK, gin.H{"contest": contest})
+		return
+	}
+
+	catalog, err := languages.Enabled()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch languages, please try again later!!"})
+		return
+	}
+
+	var userID uint
+	if user != nil {
+		userID = user.ID
+	}
+	c.JSON(http.StatusOK, gin.H{"contest": types.NewContestResponse(contest, userID, catalog)})
 }
 
 func getContest(c *gin.Context) {
@@ -122,20 +150,76 @@ func createContest(c *gin.Context) {
 		return
 	}
 
+	// The scheduler moves the contest on from here as its times pass.
+	status := reqBody.Status
+	if status != models.ContestPending && status != models.ContestActive && status != models.ContestCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown contest status " + status + "!!"})
+		return
+	}
+
+	var registrationDeadline *time.Time
+	if reqBody.RegistrationDeadline != "" {
+		deadline, err := time.Parse(time.RFC3339, reqBody.RegistrationDeadline)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid registration deadline format"})
+			return
+		}
+		deadline = deadline.UTC()
+		registrationDeadline = &deadline
+	}
+
+	if reqBody.MaxParticipants < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Max participants cannot be negative!!"})
+		return
+	}
+
+	if !judge.ValidStopPolicy(reqBody.StopPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown stop policy " + reqBody.StopPolicy + "!!"})
+		return
+	}
+
+	if !standings.ValidMode(reqBody.ScoringMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown scoring mode " + reqBody.ScoringMode + "!!"})
+		return
+	}
+	scoringMode := reqBody.ScoringMode
+	if scoringMode == "" {
+		scoringMode = standings.ModeIOI
+	}
+	penaltyMinutes := 20
+	if reqBody.PenaltyMinutes != nil {
+		if *reqBody.PenaltyMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Penalty minutes cannot be negative!!"})
+			return
+		}
+		penaltyMinutes = *reqBody.PenaltyMinutes
+	}
+
+	if reqBody.FreezeMinutes < 0 || time.Duration(reqBody.FreezeMinutes)*time.Minute >= endTime.Sub(startTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Freeze minutes must be shorter than the contest!!"})
+		return
+	}
+
 	contest := models.Contest{
-		Name:          reqBody.Name,
-		Description:   reqBody.Description,
-		StartTime:     startTime.UTC(),
-		EndTime:       endTime.UTC(),
-		IsPublic:      reqBody.IsPublic,
-		MaxDuration:   reqBody.MaxDuration,
-		CreatorID:     user.ID,
-		Status:        reqBody.Status,
-		RatingFloor:   reqBody.RatingFloor,
-		RatingCeil:    reqBody.RatingCeil,
-		IsRated:       reqBody.IsRated,
-		RatingType:    reqBody.RatingType,
-		RatingKFactor: reqBody.RatingKFactor,
+		Name:                 reqBody.Name,
+		Description:          reqBody.Description,
+		StartTime:            startTime.UTC(),
+		EndTime:              endTime.UTC(),
+		IsPublic:             reqBody.IsPublic,
+		MaxDuration:          reqBody.MaxDuration,
+		CreatorID:            user.ID,
+		Status:               status,
+		RatingFloor:          reqBody.RatingFloor,
+		RatingCeil:           reqBody.RatingCeil,
+		IsRated:              reqBody.IsRated,
+		RatingType:           reqBody.RatingType,
+		RatingKFactor:        reqBody.RatingKFactor,
+		StopPolicy:           reqBody.StopPolicy,
+		ScoringMode:          scoringMode,
+		PenaltyMinutes:       penaltyMinutes,
+		FreezeMinutes:        reqBody.FreezeMinutes,
+		RegistrationDeadline: registrationDeadline,
+		MaxParticipants:      reqBody.MaxParticipants,
 	}
 
 	if err := db.Create(&contest).Error; err != nil {
@@ -180,37 +264,168 @@ func updateContestProblems(c *gin.Context) {
 	}
 
 	for _, problem := range reqBody.Problems {
-		var contestProblem models.Problem
-		contestProblem.ContestID = problem.ContestID
-		contestProblem.Title = problem.Title
-		contestProblem.Description = problem.Description
-		contestProblem.TimeLimit = problem.TimeLimit
-		contestProblem.MemoryLimit = problem.MemoryLimit
-		contestProblem.Difficulty = problem.Difficulty
-		contestProblem.Score = problem.Score
-		contestProblem.Rating = problem.Rating
-		contestProblem.SampleInput = problem.SampleInput
-		contestProblem.SampleOutput = problem.SampleOutput
-		contestProblem.TestCasesCount = problem.TestCasesCount
-
-		if err := db.Create(&contestProblem).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create contest problem, please try again later!!"})
+		if !judge.ValidStopPolicy(problem.StopPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown stop policy " + problem.StopPolicy + "!!"})
 			return
 		}
+		if !judge.ValidCheckerMode(problem.CheckerMode) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown checker mode " + problem.CheckerMode + "!!"})
+			return
+		}
+		if problem.CheckerMode == judge.CheckerCustom {
+			if problem.CheckerSource == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "A custom checker needs its source code!!"})
+				return
+			}
+			if _, err := languages.Find(problem.CheckerLanguage); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Checker language is not supported!!"})
+				return
+			}
+		}
+		if problem.InteractorSource != "" {
+			if _, err := languages.Find(problem.InteractorLanguage); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Interactor language is not supported!!"})
+				return
+			}
+		}
+		for _, solution := range problem.ReferenceSolutions {
+			if !validReferenceSolution(c, solution) {
+				return
+			}
+		}
 
+		groupNames := map[string]bool{}
+		for _, group := range problem.TestGroups {
+			if group.Name == "" || groupNames[group.Name] {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Test groups need unique names!!"})
+				return
+			}
+			if !judge.ValidScoringPolicy(group.ScoringPolicy) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown scoring policy " + group.ScoringPolicy + "!!"})
+				return
+			}
+			groupNames[group.Name] = true
+		}
+		for _, group := range problem.TestGroups {
+			for _, dep := range group.Dependencies {
+				if !groupNames[dep] || dep == group.Name {
+					c.JSON(http.StatusBadRequest, gin.H{"message": "Test group " + group.Name + " depends on an unknown group " + dep + "!!"})
+					return
+				}
+			}
+		}
 		for _, testCase := range problem.TestCases {
-			var problemTestCase models.TestCase
-			problemTestCase.ProblemID = contestProblem.ID
-			problemTestCase.Input = testCase.Input
-			problemTestCase.Output = testCase.Output
-			problemTestCase.IsHidden = testCase.IsHidden
-
-			if err := db.Create(&problemTestCase).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create test case, please try again later!!"})
+			if testCase.Group != "" && !groupNames[testCase.Group] {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Test case refers to an unknown group " + testCase.Group + "!!"})
 				return
 			}
 		}
 	}
 
+	// Every problem goes in together, so a failure halfway leaves the
+	// contest as it was. Validations start once the problems are committed.
+	var validate []uint
+	message := ""
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, problem := range reqBody.Problems {
+			var contestProblem models.Problem
+			contestProblem.ContestID = problem.ContestID
+			contestProblem.Title = problem.Title
+			contestProblem.Description = problem.Description
+			contestProblem.TimeLimit = problem.TimeLimit
+			contestProblem.MemoryLimit = problem.MemoryLimit
+			contestProblem.Difficulty = problem.Difficulty
+			contestProblem.Score = problem.Score
+			contestProblem.Rating = problem.Rating
+			contestProblem.SampleInput = problem.SampleInput
+			contestProblem.SampleOutput = problem.SampleOutput
+			contestProblem.TestCasesCount = problem.TestCasesCount
+			contestProblem.CheckerMode = problem.CheckerMode
+			contestProblem.CheckerAbsEps = problem.CheckerAbsEps
+			contestProblem.CheckerRelEps = problem.CheckerRelEps
+			contestProblem.CheckerSource = problem.CheckerSource
+			contestProblem.CheckerLanguage = problem.CheckerLanguage
+			contestProblem.InteractorSource = problem.InteractorSource
+			contestProblem.InteractorLanguage = problem.InteractorLanguage
+			contestProblem.StopPolicy = problem.StopPolicy
+
+			if err := tx.Create(&contestProblem).Error; err != nil {
+				message = "Could not create contest problem, please try again later!!"
+				return err
+			}
+
+			groups := map[string]*models.TestGroup{}
+			for _, group := range problem.TestGroups {
+				problemGroup := models.TestGroup{
+					ProblemID:     contestProblem.ID,
+					Name:          group.Name,
+					Points:        group.Points,
+					ScoringPolicy: group.ScoringPolicy,
+				}
+				if err := tx.Create(&problemGroup).Error; err != nil {
+					message = "Could not create test group, please try again later!!"
+					return err
+				}
+				groups[group.Name] = &problemGroup
+			}
+			for _, group := range problem.TestGroups {
+				if len(group.Dependencies) == 0 {
+					continue
+				}
+				var dependencies []models.TestGroup
+				for _, dep := range group.Dependencies {
+					dependencies = append(dependencies, *groups[dep])
+				}
+				if err := tx.Model(groups[group.Name]).Association("Dependencies").Append(dependencies); err != nil {
+					message = "Could not link test groups, please try again later!!"
+					return err
+				}
+			}
+
+			for _, testCase := range problem.TestCases {
+				var problemTestCase models.TestCase
+				problemTestCase.ProblemID = contestProblem.ID
+				problemTestCase.Input = testCase.Input
+				problemTestCase.Output = testCase.Output
+				problemTestCase.IsHidden = testCase.IsHidden
+				if group, ok := groups[testCase.Group]; ok {
+					problemTestCase.GroupID = &group.ID
+				}
+
+				if err := tx.Create(&problemTestCase).Error; err != nil {
+					message = "Could not create test case, please try again later!!"
+					return err
+				}
+			}
+
+			if len(problem.ReferenceSolutions) == 0 {
+				continue
+			}
+			for _, solution := range problem.ReferenceSolutions {
+				referenceSolution := newReferenceSolution(contestProblem.ID, solution)
+				if err := tx.Create(&referenceSolution).Error; err != nil {
+					message = "Could not create reference solution, please try again later!!"
+					return err
+				}
+			}
+			validate = append(validate, contestProblem.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		if message == "" {
+			message = "Could not update contest problems, please try again later!!"
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": message})
+		return
+	}
+
+	for _, problemID := range validate {
+		if _, err := startValidation(problemID, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not start the problem validation, please try again later!!"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Contest problems updated successfully!!"})
 }
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/languages"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/types"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterLanguageRoutes(r *gin.Engine) {
+	languageRouter := r.Group("/language")
+	{
+		languageRouter.GET("/get-all", getLanguages)
+		languageRouter.GET("/admin/get-all", getAllLanguages)
+		languageRouter.GET("/admin/judge-languages", getJudgeLanguages)
+		languageRouter.POST("/create", createLanguage)
+		languageRouter.PUT("/update/:id", updateLanguage)
+		languageRouter.DELETE("/delete/:id", deleteLanguage)
+	}
+}
+
+func getLanguages(c *gin.Context) {
+	enabled, err := languages.Enabled()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch languages, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"languages": enabled})
+}
+
+func getAllLanguages(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var all []models.Language
+	if err := config.GetDB().Order("name").Find(&all).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch languages, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"languages": all})
+}
+
+// getJudgeLanguages lists what the configured backend offers, so admins can
+// look up the judge_id to put in the catalog.
+func getJudgeLanguages(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	backendLanguages, err := judge.GetJudge().Languages(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"message": "Could not fetch languages from the judge: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backend": judge.GetJudge().Name(), "languages": backendLanguages})
+}
+
+func createLanguage(c *gin.Context) {
+	var reqBody types.LanguageRequest
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Request type is invalid, please fix the sent data and its types!!"})
+		return
+	}
+
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var language models.Language
+	applyLanguageRequest(&language, reqBody)
+
+	if err := config.GetDB().Create(&language).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create language, the slug may already exist!!"})
+		return
+	}
+	languages.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Language created successfully!!", "language": language})
+}
+
+func updateLanguage(c *gin.Context) {
+	var reqBody types.LanguageRequest
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Request type is invalid, please fix the sent data and its types!!"})
+		return
+	}
+
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var language models.Language
+
+	if err := db.First(&language, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Language not found!!"})
+		return
+	}
+
+	applyLanguageRequest(&language, reqBody)
+
+	if err := db.Save(&language).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update language, please try again later!!"})
+		return
+	}
+	languages.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Language updated successfully!!", "language": language})
+}
+
+func deleteLanguage(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	res := config.GetDB().Delete(&models.Language{}, c.Param("id"))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete language, please try again later!!"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Language not found!!"})
+		return
+	}
+	languages.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Language deleted successfully!!"})
+}
+
+func applyLanguageRequest(language *models.Language, reqBody types.LanguageRequest) {
+	language.Slug = languages.Normalize(reqBody.Slug)
+	language.Name = reqBody.Name
+	language.Version = reqBody.Version
+	language.JudgeID = reqBody.JudgeID
+	language.SourceFile = reqBody.SourceFile
+	language.CompileCommand = reqBody.CompileCommand
+	language.RunCommand = reqBody.RunCommand
+	language.Enabled = reqBody.Enabled
+
+	language.TimeMultiplier = reqBody.TimeMultiplier
+	if language.TimeMultiplier == 0 {
+		language.TimeMultiplier = 1
+	}
+	language.TimeOffset = reqBody.TimeOffset
+	language.MemoryOffset = reqBody.MemoryOffset
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"time"
+
 	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/languages"
 	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"github.com/ankush-web-eng/contest-backend/types"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,6 +16,7 @@ func RegisterLiveRoutes(r *gin.Engine) {
 	{
 		liveRouter.GET("/get/:contestId/:problemId", getAllProblems)
 		liveRouter.GET("/get/submissions/:problemId", getAllSubmissions)
+		liveRouter.GET("/get/scores/:contestId", getBestScores)
 	}
 }
 
@@ -33,13 +39,35 @@ func getAllProblems(c *gin.Context) {
 		return
 	}
 
+	query := db.Preload("Contest").Preload("TestCases").Preload("TestGroups").Preload("LanguageLimits")
+	if user.IsAdmin {
+		query = query.Preload("Submissions")
+	} else {
+		query = query.Preload("Submissions", "user_id = ?", user.ID)
+	}
+
 	var problem models.Problem
-	if err := db.Preload("TestCases").Preload("Submissions").Where("contest_id = ? AND id = ?", contestId, problemId).First(&problem).Error; err != nil {
+	if err := query.Where("contest_id = ? AND id = ?", contestId, problemId).First(&problem).Error; err != nil {
+		c.JSON(404, gin.H{"message": "Problem not found!!"})
+		return
+	}
+
+	if (!problem.IsPublished || problem.Contest.Status == models.ContestPending) && !user.IsAdmin {
 		c.JSON(404, gin.H{"message": "Problem not found!!"})
 		return
 	}
 
-	c.JSON(200, gin.H{"problem": problem})
+	catalog, err := languages.Enabled()
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Could not fetch languages, please try again later!!"})
+		return
+	}
+
+	if user.IsAdmin {
+		c.JSON(200, gin.H{"problem": problem, "language_limits": types.NewLanguageLimitResponses(&problem, catalog)})
+		return
+	}
+	c.JSON(200, gin.H{"problem": types.NewProblemResponse(problem, user.ID, catalog, standings.FreezeStarted(&problem.Contest, time.Now()))})
 }
 
 func getAllSubmissions(c *gin.Context) {
@@ -68,3 +96,22 @@ func getAllSubmissions(c *gin.Context) {
 
 	c.JSON(200, gin.H{"submissions": submissions})
 }
+
+func getBestScores(c *gin.Context) {
+	contestId := c.Param("contestId")
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var db = config.GetDB()
+
+	var scores []models.UserProblemScore
+	if err := db.Where("contest_id = ? AND user_id = ?", contestId, user.ID).Find(&scores).Error; err != nil {
+		c.JSON(500, gin.H{"message": "Could not fetch scores, please try again later!!"})
+		return
+	}
+
+	c.JSON(200, gin.H{"scores": scores})
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// windowLength is how long one participant gets, in a windowed contest or a
+// virtual run.
+func windowLength(contest *models.Contest) time.Duration {
+	if contest.MaxDuration > 0 {
+		return time.Duration(contest.MaxDuration) * time.Minute
+	}
+	return contest.EndTime.Sub(contest.StartTime)
+}
+
+// participationFor decides what a submission the user makes now counts as.
+// A non-empty message means the user may not submit to the contest right
+// now. While the contest runs only registered users who are not disqualified
+// take part; admins submit as practice instead.
+func participationFor(db *gorm.DB, user *models.User, contest *models.Contest, now time.Time) (string, *uint, string, error) {
+	if now.Before(contest.StartTime) {
+		return models.ParticipationPractice, nil, "", nil
+	}
+
+	if now.Before(contest.EndTime) {
+		if contest.MaxDuration == 0 {
+			var registrations []models.UserContest
+			if err := db.Where("user_id = ? AND contest_id = ?", user.ID, contest.ID).
+				Limit(1).
+				Find(&registrations).Error; err != nil {
+				return "", nil, "", err
+			}
+			switch {
+			case len(registrations) > 0 && registrations[0].Status != models.RegistrationDisqualified:
+				return models.ParticipationContest, nil, "", nil
+			case user.IsAdmin:
+				return models.ParticipationPractice, nil, "", nil
+			case len(registrations) > 0:
+				return "", nil, "You have been disqualified from this contest!!", nil
+			default:
+				return "", nil, "You are not registered for this contest!!", nil
+			}
+		}
+
+		var windows int64
+		if err := db.Model(&models.UserContest{}).
+			Where("user_id = ? AND contest_id = ? AND status = ? AND start_time <= ? AND end_time > ?",
+				user.ID, contest.ID, models.RegistrationStarted, now, now).
+			Count(&windows).Error; err != nil {
+			return "", nil, "", err
+		}
+		switch {
+		case windows > 0:
+			return models.ParticipationContest, nil, "", nil
+		case user.IsAdmin:
+			return models.ParticipationPractice, nil, "", nil
+		default:
+			return "", nil, "You can only submit during your contest window!!", nil
+		}
+	}
+
+	var virtual []models.VirtualParticipation
+	if err := db.Where("user_id = ? AND contest_id = ? AND start_time <= ? AND end_time > ?", user.ID, contest.ID, now, now).
+		Limit(1).
+		Find(&virtual).Error; err != nil {
+		return "", nil, "", err
+	}
+	if len(virtual) > 0 {
+		return models.ParticipationVirtual, &virtual[0].ID, "", nil
+	}
+	return models.ParticipationPractice, nil, "", nil
+}
+
+// startContestWindow begins the caller's personal window in a contest with
+// a MaxDuration. The window never runs past the contest's end.
+func startContestWindow(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+	if contest.MaxDuration == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "This contest has no personal windows, it starts for everyone at once!!"})
+		return
+	}
+
+	now := time.Now().UTC()
+	if now.Before(contest.StartTime) || !now.Before(contest.EndTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "You can only start your window while the contest is running!!"})
+		return
+	}
+
+	endTime := now.Add(windowLength(&contest))
+	if endTime.After(contest.EndTime) {
+		endTime = contest.EndTime
+	}
+
+	// Only a registered user's first start matches.
+	res := db.Model(&models.UserContest{}).
+		Where("user_id = ? AND contest_id = ? AND status = ?", user.ID, contest.ID, models.RegistrationRegistered).
+		Updates(map[string]interface{}{
+			"status":     models.RegistrationStarted,
+			"start_time": now,
+			"end_time":   endTime,
+		})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not start your window, please try again later!!"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "You are not registered for this contest or have already started!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Your window has started!!", "start_time": now, "end_time": endTime})
+}
+
+// startVirtualParticipation reruns a finished contest for the caller.
+func startVirtualParticipation(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+
+	now := time.Now().UTC()
+	if now.Before(contest.EndTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Virtual participation opens once the contest has ended!!"})
+		return
+	}
+
+	virtual := models.VirtualParticipation{
+		UserID:    user.ID,
+		ContestID: contest.ID,
+		StartTime: now,
+		EndTime:   now.Add(windowLength(&contest)),
+	}
+	running := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Locking the user keeps two requests from both starting a run.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, user.ID).Error; err != nil {
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&models.VirtualParticipation{}).
+			Where("user_id = ? AND end_time > ?", user.ID, now).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			running = true
+			return nil
+		}
+		return tx.Omit(clause.Associations).Create(&virtual).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not start the virtual participation, please try again later!!"})
+		return
+	}
+	if running {
+		c.JSON(http.StatusConflict, gin.H{"message": "You already have a virtual participation running!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Virtual participation started!!", "virtual_participation": virtual})
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/plagiarism"
+	"github.com/ankush-web-eng/contest-backend/rating"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"github.com/ankush-web-eng/contest-backend/types"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterPlagiarismRoutes(r *gin.Engine) {
+	plagiarismRouter := r.Group("/plagiarism")
+	{
+		plagiarismRouter.POST("/admin/check/contest/:id", checkContestPlagiarism)
+		plagiarismRouter.POST("/admin/check/problem/:id", checkProblemPlagiarism)
+		plagiarismRouter.GET("/admin/reports/:contestId", getPlagiarismReports)
+		plagiarismRouter.GET("/admin/report/:id", getPlagiarismReport)
+		plagiarismRouter.GET("/admin/pair/:id", getPlagiarismPair)
+		plagiarismRouter.PUT("/admin/pair/:id/review", reviewPlagiarismPair)
+	}
+}
+
+func checkContestPlagiarism(c *gin.Context) {
+	admin, ok := adminUser(c)
+	if !ok {
+		return
+	}
+
+	var contest models.Contest
+	if err := config.GetDB().First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+
+	startPlagiarismCheck(c, admin, contest.ID, nil)
+}
+
+func checkProblemPlagiarism(c *gin.Context) {
+	admin, ok := adminUser(c)
+	if !ok {
+		return
+	}
+
+	var problem models.Problem
+	if err := config.GetDB().First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+
+	startPlagiarismCheck(c, admin, problem.ContestID, &problem.ID)
+}
+
+func startPlagiarismCheck(c *gin.Context, admin *models.User, contestID uint, problemID *uint) {
+	var reqBody types.PlagiarismCheckRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Request type is invalid, please fix the sent data and its types!!"})
+			return
+		}
+	}
+
+	threshold := reqBody.Threshold
+	if threshold == 0 {
+		threshold = plagiarism.DefaultThreshold
+	}
+	if threshold < 0 || threshold > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Threshold must be between 0 and 1!!"})
+		return
+	}
+
+	report := models.PlagiarismReport{
+		ContestID: contestID,
+		ProblemID: problemID,
+		Status:    plagiarism.ReportRunning,
+		Threshold: threshold,
+		CreatedBy: admin.ID,
+	}
+	if err := config.GetDB().Create(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not start the plagiarism check, please try again later!!"})
+		return
+	}
+
+	go plagiarism.Run(report.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Plagiarism check started", "report": report})
+}
+
+func getPlagiarismReports(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var reports []models.PlagiarismReport
+	if err := config.GetDB().Where("contest_id = ?", c.Param("contestId")).Order("id DESC").Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch reports, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+func getPlagiarismReport(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var report models.PlagiarismReport
+	if err := config.GetDB().
+		Preload("Pairs", func(db *gorm.DB) *gorm.DB { return db.Order("score DESC, id") }).
+		First(&report, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Report not found!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// getPlagiarismPair returns a pair with both sources, so the matched line
+// ranges can be shown side by side.
+func getPlagiarismPair(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var pair models.PlagiarismPair
+	if err := db.Preload("Matches").First(&pair, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Pair not found!!"})
+		return
+	}
+
+	var first, second models.Submission
+	if err := db.First(&first, pair.FirstSubmissionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Submission not found!!"})
+		return
+	}
+	if err := db.First(&second, pair.SecondSubmissionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Submission not found!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pair": pair, "first": first, "second": second})
+}
+
+// reviewPlagiarismPair records the admin's decision. Confirming a pair can
+// disqualify either or both of its users from the contest, which takes back
+// their rating changes if the contest was already rated.
+func reviewPlagiarismPair(c *gin.Context) {
+	var reqBody types.PlagiarismReviewRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Request type is invalid, please fix the sent data and its types!!"})
+		return
+	}
+
+	admin, ok := adminUser(c)
+	if !ok {
+		return
+	}
+
+	if reqBody.Status != plagiarism.PairConfirmed && reqBody.Status != plagiarism.PairDismissed {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Status must be confirmed or dismissed!!"})
+		return
+	}
+	if len(reqBody.Disqualify) > 0 && reqBody.Status != plagiarism.PairConfirmed {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Only a confirmed pair can disqualify users!!"})
+		return
+	}
+
+	db := config.GetDB()
+	var pair models.PlagiarismPair
+	if err := db.First(&pair, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Pair not found!!"})
+		return
+	}
+
+	for _, userID := range reqBody.Disqualify {
+		if userID != pair.FirstUserID && userID != pair.SecondUserID {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Only the users of this pair can be disqualified!!"})
+			return
+		}
+	}
+
+	var report models.PlagiarismReport
+	if err := db.First(&report, pair.ReportID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Report not found!!"})
+		return
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&pair).Updates(map[string]interface{}{
+			"status":      reqBody.Status,
+			"reviewed_by": admin.ID,
+			"reviewed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if len(reqBody.Disqualify) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.UserContest{}).
+			Where("contest_id = ? AND user_id IN ?", report.ContestID, reqBody.Disqualify).
+			Update("status", models.RegistrationDisqualified).Error; err != nil {
+			return err
+		}
+
+		var contest models.Contest
+		if err := tx.First(&contest, report.ContestID).Error; err != nil {
+			return err
+		}
+		if contest.RatedAt != nil {
+			if err := rating.Revert(tx, &contest, reqBody.Disqualify); err != nil {
+				return err
+			}
+		}
+		return standings.Refresh(tx, &contest)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save the review, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review saved", "pair": pair})
+}
@@ -0,0 +1,420 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/languages"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/queue"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"github.com/ankush-web-eng/contest-backend/types"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func RegisterProblemRoutes(r *gin.Engine) {
+	problemRouter := r.Group("/problem")
+	{
+		problemRouter.GET("/admin/:id/reference-solutions", getReferenceSolutions)
+		problemRouter.POST("/admin/:id/reference-solutions", addReferenceSolution)
+		problemRouter.DELETE("/admin/reference-solution/:id", deleteReferenceSolution)
+		problemRouter.POST("/admin/:id/validate", validateProblem)
+		problemRouter.GET("/admin/:id/validations", getProblemValidations)
+		problemRouter.POST("/admin/:id/publish", publishProblem)
+		problemRouter.POST("/admin/:id/unpublish", unpublishProblem)
+		problemRouter.GET("/admin/:id/language-limits", getLanguageLimits)
+		problemRouter.PUT("/admin/:id/language-limits", setLanguageLimit)
+		problemRouter.DELETE("/admin/:id/language-limits/:language", deleteLanguageLimit)
+	}
+}
+
+// validReferenceSolution checks a reference solution request, writing the
+// error response itself.
+func validReferenceSolution(c *gin.Context, solution types.ReferenceSolutionRequest) bool {
+	if solution.Name == "" || solution.Source == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A reference solution needs a name and its source code!!"})
+		return false
+	}
+	if !queue.ValidExpectedVerdict(solution.ExpectedVerdict) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Expected verdict must be AC, WA or TLE!!"})
+		return false
+	}
+	if _, err := languages.Find(solution.Language); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Reference solution language is not supported!!"})
+		return false
+	}
+	return true
+}
+
+func newReferenceSolution(problemID uint, solution types.ReferenceSolutionRequest) models.ReferenceSolution {
+	return models.ReferenceSolution{
+		ProblemID:       problemID,
+		Name:            solution.Name,
+		Language:        solution.Language,
+		Source:          solution.Source,
+		ExpectedVerdict: solution.ExpectedVerdict,
+	}
+}
+
+// startValidation records a running validation and judges it in the
+// background.
+func startValidation(problemID, adminID uint) (*models.ProblemValidation, error) {
+	validation := models.ProblemValidation{
+		ProblemID: problemID,
+		Status:    queue.ValidationRunning,
+		CreatedBy: adminID,
+	}
+	if err := config.GetDB().Create(&validation).Error; err != nil {
+		return nil, err
+	}
+	go queue.RunValidation(validation.ID)
+	return &validation, nil
+}
+
+func getReferenceSolutions(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var solutions []models.ReferenceSolution
+	if err := config.GetDB().Where("problem_id = ?", c.Param("id")).Order("id").Find(&solutions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch reference solutions, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reference_solutions": solutions})
+}
+
+// addReferenceSolution attaches a solution and unpublishes the problem until
+// it is validated again with it.
+func addReferenceSolution(c *gin.Context) {
+	var reqBody types.ReferenceSolutionRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Request type is invalid, please fix the sent data and its types!!"})
+		return
+	}
+
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+	if !validReferenceSolution(c, reqBody) {
+		return
+	}
+
+	db := config.GetDB()
+	var problem models.Problem
+	if err := db.First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+
+	solution := newReferenceSolution(problem.ID, reqBody)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&solution).Error; err != nil {
+			return err
+		}
+		return setPublished(tx, &problem, false)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not add the reference solution, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reference solution added, validate the problem to publish it", "reference_solution": solution})
+}
+
+// deleteReferenceSolution removes a solution and, like adding one,
+// unpublishes the problem until it is validated again without it.
+func deleteReferenceSolution(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var solution models.ReferenceSolution
+	if err := db.First(&solution, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Reference solution not found!!"})
+		return
+	}
+
+	var problem models.Problem
+	if err := db.First(&problem, solution.ProblemID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&solution).Error; err != nil {
+			return err
+		}
+		return setPublished(tx, &problem, false)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete the reference solution, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reference solution deleted, validate the problem to publish it"})
+}
+
+// validateProblem runs the reference solutions against the tests. A problem
+// can only be published after a passing validation, so it needs at least one
+// reference solution expected to be accepted.
+func validateProblem(c *gin.Context) {
+	admin, ok := adminUser(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var problem models.Problem
+	if err := db.First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+	if !requireAcceptedSolution(c, db, problem.ID) {
+		return
+	}
+
+	validation, err := startValidation(problem.ID, admin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not start the validation, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Validation started", "validation": validation})
+}
+
+func getProblemValidations(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var validations []models.ProblemValidation
+	if err := config.GetDB().Preload("Results").
+		Where("problem_id = ?", c.Param("id")).
+		Order("id DESC").
+		Find(&validations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch validations, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"validations": validations})
+}
+
+// publishProblem needs a reference solution expected to be accepted, and the
+// latest validation to have passed on exactly the reference solutions the
+// problem has now, none of them changed since.
+func publishProblem(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var problem models.Problem
+	if err := db.First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+	if !requireAcceptedSolution(c, db, problem.ID) {
+		return
+	}
+
+	var validation models.ProblemValidation
+	if err := db.Where("problem_id = ?", problem.ID).Order("id DESC").First(&validation).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "The problem has not been validated yet!!"})
+		return
+	}
+	if validation.Status != queue.ValidationPassed {
+		c.JSON(http.StatusConflict, gin.H{"message": "The latest validation has not passed!!", "validation": validation})
+		return
+	}
+
+	changed, err := solutionsChangedSince(db, &validation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not publish the problem, please try again later!!"})
+		return
+	}
+	if changed {
+		c.JSON(http.StatusConflict, gin.H{"message": "Reference solutions changed since the last validation, validate again!!"})
+		return
+	}
+
+	if err := setPublished(db, &problem, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not publish the problem, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Problem published"})
+}
+
+// setPublished shows or hides the problem, and with it its column on the
+// public standings.
+func setPublished(tx *gorm.DB, problem *models.Problem, published bool) error {
+	if err := tx.Model(problem).Update("is_published", published).Error; err != nil {
+		return err
+	}
+	return standings.MarkStale(tx, problem.ContestID)
+}
+
+// requireAcceptedSolution checks that the problem has a reference solution
+// expected to be accepted, writing the error response itself.
+func requireAcceptedSolution(c *gin.Context, db *gorm.DB, problemID uint) bool {
+	var count int64
+	if err := db.Model(&models.ReferenceSolution{}).
+		Where("problem_id = ? AND expected_verdict = ?", problemID, judge.VerdictAccepted).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not check the reference solutions, please try again later!!"})
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "Add a reference solution that must be accepted before validating or publishing the problem!!"})
+		return false
+	}
+	return true
+}
+
+// solutionsChangedSince reports whether a reference solution was added,
+// deleted or edited after the validation ran.
+func solutionsChangedSince(db *gorm.DB, validation *models.ProblemValidation) (bool, error) {
+	var validated []uint
+	if err := db.Model(&models.ReferenceSolutionResult{}).
+		Where("validation_id = ?", validation.ID).
+		Pluck("reference_solution_id", &validated).Error; err != nil {
+		return false, err
+	}
+	var solutions []models.ReferenceSolution
+	if err := db.Select("id", "updated_at").Where("problem_id = ?", validation.ProblemID).Find(&solutions).Error; err != nil {
+		return false, err
+	}
+
+	remaining := make(map[uint]bool, len(validated))
+	for _, id := range validated {
+		remaining[id] = true
+	}
+	for _, solution := range solutions {
+		if !remaining[solution.ID] || solution.UpdatedAt.After(validation.CreatedAt) {
+			return true, nil
+		}
+		delete(remaining, solution.ID)
+	}
+	return len(remaining) > 0, nil
+}
+
+func unpublishProblem(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var problem models.Problem
+	if err := db.First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+	if err := setPublished(db, &problem, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not unpublish the problem, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Problem unpublished"})
+}
+
+// getLanguageLimits returns the problem's overrides along with the effective
+// limits in every enabled language.
+func getLanguageLimits(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	var problem models.Problem
+	if err := config.GetDB().Preload("LanguageLimits").First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+
+	catalog, err := languages.Enabled()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch languages, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overrides":       problem.LanguageLimits,
+		"language_limits": types.NewLanguageLimitResponses(&problem, catalog),
+	})
+}
+
+// setLanguageLimit creates or replaces the problem's override for a language.
+func setLanguageLimit(c *gin.Context) {
+	var reqBody types.ProblemLanguageLimitRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Request type is invalid, please fix the sent data and its types!!"})
+		return
+	}
+
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	language, err := languages.Find(reqBody.Language)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Language is not supported!!"})
+		return
+	}
+	if reqBody.TimeMultiplier != nil && *reqBody.TimeMultiplier <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Time multiplier must be positive!!"})
+		return
+	}
+
+	db := config.GetDB()
+	var problem models.Problem
+	if err := db.First(&problem, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Problem not found!!"})
+		return
+	}
+
+	override := models.ProblemLanguageLimit{
+		ProblemID:      problem.ID,
+		Language:       language.Slug,
+		TimeMultiplier: reqBody.TimeMultiplier,
+		TimeOffset:     reqBody.TimeOffset,
+		MemoryOffset:   reqBody.MemoryOffset,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "problem_id"}, {Name: "language"}},
+		DoUpdates: clause.AssignmentColumns([]string{"time_multiplier", "time_offset", "memory_offset", "updated_at"}),
+	}).Create(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save the language limits, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Language limits saved",
+		"override":        override,
+		"effective_limit": languages.EffectiveLimits(&problem, language, &override),
+	})
+}
+
+func deleteLanguageLimit(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	res := config.GetDB().
+		Where("problem_id = ? AND language = ?", c.Param("id"), languages.Normalize(c.Param("language"))).
+		Delete(&models.ProblemLanguageLimit{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete the language limits, please try again later!!"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Language limits not found!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Language limits deleted"})
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// registrationError is a rejected registration, sent back as is.
+type registrationError struct {
+	status  int
+	message string
+}
+
+func (e *registrationError) Error() string {
+	return e.message
+}
+
+// registrationDeadline is the last moment the contest accepts registrations.
+// Without one set, a windowed contest takes them as long as it runs.
+func registrationDeadline(contest *models.Contest) time.Time {
+	switch {
+	case contest.RegistrationDeadline != nil:
+		return *contest.RegistrationDeadline
+	case contest.MaxDuration > 0:
+		return contest.EndTime
+	default:
+		return contest.StartTime
+	}
+}
+
+// eligible reports whether a rating fits the contest's band.
+func eligible(contest *models.Contest, rating int) bool {
+	if contest.RatingFloor != 0 && rating < contest.RatingFloor {
+		return false
+	}
+	if contest.RatingCeil != 0 && rating > contest.RatingCeil {
+		return false
+	}
+	return true
+}
+
+// registerForContest signs the caller up. The contest row is locked so that
+// concurrent registrations cannot exceed MaxParticipants.
+func registerForContest(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var registration models.UserContest
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		var contest models.Contest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&contest, c.Param("id")).Error; err != nil {
+			return &registrationError{http.StatusNotFound, "Contest not found!!"}
+		}
+
+		if !time.Now().UTC().Before(registrationDeadline(&contest)) {
+			return &registrationError{http.StatusBadRequest, "Registration for this contest is closed!!"}
+		}
+		if !eligible(&contest, user.CurrentRating) {
+			return &registrationError{http.StatusForbidden, "Your rating is outside the rating range of this contest!!"}
+		}
+
+		var existing int64
+		if err := tx.Model(&models.UserContest{}).
+			Where("user_id = ? AND contest_id = ?", user.ID, contest.ID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return &registrationError{http.StatusConflict, "You are already registered for this contest!!"}
+		}
+
+		if contest.MaxParticipants > 0 {
+			var participants int64
+			if err := tx.Model(&models.UserContest{}).Where("contest_id = ?", contest.ID).Count(&participants).Error; err != nil {
+				return err
+			}
+			if participants >= int64(contest.MaxParticipants) {
+				return &registrationError{http.StatusConflict, "This contest is full!!"}
+			}
+		}
+
+		registration = models.UserContest{
+			UserID:        user.ID,
+			ContestID:     contest.ID,
+			Status:        models.RegistrationRegistered,
+			InitialRating: user.CurrentRating,
+		}
+		return tx.Omit(clause.Associations).Create(&registration).Error
+	})
+
+	var rejected *registrationError
+	if errors.As(err, &rejected) {
+		c.JSON(rejected.status, gin.H{"message": rejected.message})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register for the contest, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Registered successfully!!", "registration": registration})
+}
+
+// unregisterFromContest withdraws the caller's registration, only before the
+// contest starts.
+func unregisterFromContest(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+
+	if !time.Now().UTC().Before(contest.StartTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "You cannot unregister once the contest has started!!"})
+		return
+	}
+
+	res := db.Where("user_id = ? AND contest_id = ? AND status = ?", user.ID, contest.ID, models.RegistrationRegistered).
+		Delete(&models.UserContest{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not unregister from the contest, please try again later!!"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "You are not registered for this contest!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unregistered successfully!!"})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser resolves the session cookie, writing the error response itself
+// when there is no valid session.
+func currentUser(c *gin.Context) (*models.User, bool) {
+	sessionToken, err := c.Cookie("session_token")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Session Token is invalid, login and try again!!"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := config.GetDB().Where("session_token = ?", sessionToken).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized access!!"})
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// optionalUser resolves the session cookie for routes that anonymous
+// visitors may also use, returning nil without a valid session.
+func optionalUser(c *gin.Context) *models.User {
+	sessionToken, err := c.Cookie("session_token")
+	if err != nil {
+		return nil
+	}
+
+	var user models.User
+	if err := config.GetDB().Where("session_token = ?", sessionToken).First(&user).Error; err != nil {
+		return nil
+	}
+	return &user
+}
+
+func adminUser(c *gin.Context) (*models.User, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	if !user.IsAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized access. You are not admin!!"})
+		return nil, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/queue"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultStandingsPageSize = 50
+	maxStandingsPageSize     = 200
+)
+
+// getStandings returns one page of a cohort's standings with a cell per
+// problem. Virtual runs are a separate cohort, ranked among themselves and
+// never rated. Everyone but admins gets the public standings the scheduler
+// stored, where late submissions show as pending while the contest is
+// frozen; admins get the real results, computed on the spot.
+func getStandings(c *gin.Context) {
+	cohort := c.DefaultQuery("cohort", standings.CohortOfficial)
+	if cohort != standings.CohortOfficial && cohort != standings.CohortVirtual {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Cohort must be official or virtual!!"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Page must be a positive number!!"})
+		return
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultStandingsPageSize)))
+	if err != nil || perPage < 1 || perPage > maxStandingsPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Per page must be between 1 and " + strconv.Itoa(maxStandingsPageSize) + "!!"})
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+
+	user := optionalUser(c)
+	hideFrozen := user == nil || !user.IsAdmin
+
+	var board *standings.Standings
+	if hideFrozen {
+		board, err = standings.Public(db, &contest, cohort)
+	} else {
+		board, err = standings.Compute(db, &contest, cohort, false)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch standings, please try again later!!"})
+		return
+	}
+
+	total := len(board.Rows)
+	from := min((page-1)*perPage, total)
+	to := min(from+perPage, total)
+
+	c.JSON(http.StatusOK, gin.H{
+		"mode":     board.Mode,
+		"cohort":   board.Cohort,
+		"rated":    contest.IsRated && cohort == standings.CohortOfficial,
+		"frozen":   hideFrozen && cohort == standings.CohortOfficial && standings.Frozen(&contest),
+		"problems": board.Problems,
+		"rows":     board.Rows[from:to],
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// getResolver shows the standings the way the public sees them, for the
+// admin driving the resolver.
+func getResolver(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+
+	board, err := standings.Compute(db, &contest, standings.CohortOfficial, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch standings, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"frozen": standings.Frozen(&contest), "standings": board})
+}
+
+// resolveStep reveals the next frozen cell. Once nothing is left it
+// unfreezes the contest and reports done.
+func resolveStep(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+
+	// A result still being judged cannot be revealed.
+	var judging int64
+	if err := db.Model(&models.Submission{}).
+		Where("contest_id = ? AND participation_type = ? AND status IN ?",
+			contest.ID, models.ParticipationContest, []string{queue.StatusQueued, queue.StatusJudging}).
+		Count(&judging).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not check pending submissions, please try again later!!"})
+		return
+	}
+	if judging > 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "Some contest submissions are still being judged, try again once they are done!!"})
+		return
+	}
+
+	var reveal *standings.Reveal
+	var board *standings.Standings
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reveal, board, err = standings.Step(tx, contest.ID, time.Now().UTC())
+		return err
+	})
+	switch {
+	case errors.Is(err, standings.ErrNotFrozen), errors.Is(err, standings.ErrContestRunning):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot resolve: " + err.Error() + "!!"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not resolve the next step, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"done":      reveal == nil,
+		"reveal":    reveal,
+		"standings": board,
+	})
+}
+
+// unfreezeStandings reveals everything at once, skipping the resolver.
+func unfreezeStandings(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	db := config.GetDB()
+	var contest models.Contest
+	if err := db.First(&contest, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Contest not found!!"})
+		return
+	}
+	if !standings.Frozen(&contest) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The standings are not frozen!!"})
+		return
+	}
+
+	if err := standings.Unfreeze(db, &contest, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not unfreeze the standings, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Standings unfrozen"})
+}
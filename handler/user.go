@@ -2,12 +2,14 @@ package handler
 
 import (
 	"io"
+	"log"
 	"net/http"
 	"os"
 
 	"github.com/ankush-web-eng/contest-backend/config"
 	"github.com/ankush-web-eng/contest-backend/helpers"
 	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/stats"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,6 +18,7 @@ func RegisterUserRoutes(r *gin.Engine) {
 	{
 		userRouter.POST("/image-upload", updateProfilePicture)
 		userRouter.POST("/update-details", UpdateUserDetails)
+		userRouter.POST("/admin/rebuild-stats", rebuildStats)
 	}
 }
 
@@ -127,3 +130,19 @@ func UpdateUserDetails(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "User details updated successfully"})
 }
+
+// rebuildStats recomputes submission counters, solved counts and streaks for
+// every problem and user, e.g. after a rejudge changed verdicts.
+func rebuildStats(c *gin.Context) {
+	if _, ok := adminUser(c); !ok {
+		return
+	}
+
+	if err := stats.Rebuild(); err != nil {
+		log.Println("Failed to rebuild statistics:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not rebuild statistics, please try again later!!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Statistics rebuilt"})
+}
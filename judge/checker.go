@@ -0,0 +1,185 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	CheckerExact           = "exact"
+	CheckerTokens          = "tokens"
+	CheckerFloat           = "float"
+	CheckerCaseInsensitive = "case_insensitive"
+	CheckerCustom          = "custom"
+)
+
+const defaultFloatEpsilon = 1e-6
+
+// Custom checkers follow the testlib convention: they are run as
+// `checker input.txt output.txt answer.txt`, exit 0 for accepted, 1 for
+// wrong answer, 2 for presentation error, and explain themselves on stderr.
+const (
+	checkerInputFile  = "input.txt"
+	checkerOutputFile = "output.txt"
+	checkerAnswerFile = "answer.txt"
+)
+
+var ErrCustomCheckerUnavailable = errors.New("custom checkers need the sandbox backend")
+
+// CheckResult carries AC, WA, or IE when the checker itself failed.
+type CheckResult struct {
+	Verdict string
+	Message string
+}
+
+type Checker interface {
+	Check(ctx context.Context, input, output, answer string) (CheckResult, error)
+}
+
+type CheckerSpec struct {
+	Mode     string
+	AbsEps   float64
+	RelEps   float64
+	Source   string
+	Language Language
+}
+
+func ValidCheckerMode(mode string) bool {
+	switch mode {
+	case "", CheckerExact, CheckerTokens, CheckerFloat, CheckerCaseInsensitive, CheckerCustom:
+		return true
+	}
+	return false
+}
+
+func NewChecker(spec CheckerSpec) (Checker, error) {
+	switch spec.Mode {
+	case "", CheckerExact:
+		return exactChecker{}, nil
+	case CheckerTokens:
+		return tokenChecker{equal: func(a, b string) bool { return a == b }}, nil
+	case CheckerCaseInsensitive:
+		return tokenChecker{equal: strings.EqualFold}, nil
+	case CheckerFloat:
+		absEps, relEps := spec.AbsEps, spec.RelEps
+		if absEps == 0 && relEps == 0 {
+			absEps, relEps = defaultFloatEpsilon, defaultFloatEpsilon
+		}
+		return tokenChecker{equal: func(a, b string) bool {
+			return floatTokensEqual(a, b, absEps, relEps)
+		}}, nil
+	case CheckerCustom:
+		runner := GetCheckerJudge()
+		if runner == nil {
+			return nil, ErrCustomCheckerUnavailable
+		}
+		if spec.Source == "" {
+			return nil, errors.New("custom checker has no source")
+		}
+		return customChecker{runner: runner, source: spec.Source, language: spec.Language}, nil
+	}
+	return nil, fmt.Errorf("unknown checker mode %q", spec.Mode)
+}
+
+// exactChecker compares the whole output, ignoring surrounding whitespace
+// and line ending style.
+type exactChecker struct{}
+
+func (exactChecker) Check(ctx context.Context, input, output, answer string) (CheckResult, error) {
+	normalize := func(s string) string {
+		return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+	}
+	if normalize(output) == normalize(answer) {
+		return CheckResult{Verdict: VerdictAccepted}, nil
+	}
+	return CheckResult{Verdict: VerdictWrongAnswer, Message: "output differs from the expected answer"}, nil
+}
+
+type tokenChecker struct {
+	equal func(output, answer string) bool
+}
+
+func (t tokenChecker) Check(ctx context.Context, input, output, answer string) (CheckResult, error) {
+	got, want := strings.Fields(output), strings.Fields(answer)
+
+	// Messages reach contestants, so they say nothing about the answer.
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if !t.equal(got[i], want[i]) {
+			return CheckResult{
+				Verdict: VerdictWrongAnswer,
+				Message: fmt.Sprintf("token %d is wrong: found %q", i+1, truncateToken(got[i])),
+			}, nil
+		}
+	}
+	if len(got) < len(want) {
+		return CheckResult{Verdict: VerdictWrongAnswer, Message: "output ends early"}, nil
+	}
+	if len(got) > len(want) {
+		return CheckResult{Verdict: VerdictWrongAnswer, Message: "output has extra tokens"}, nil
+	}
+	return CheckResult{Verdict: VerdictAccepted}, nil
+}
+
+// floatTokensEqual accepts the output when it is within either epsilon of
+// the answer; tokens that are not numbers must match exactly.
+func floatTokensEqual(output, answer string, absEps, relEps float64) bool {
+	want, errWant := strconv.ParseFloat(answer, 64)
+	got, errGot := strconv.ParseFloat(output, 64)
+	if errWant != nil || errGot != nil {
+		return output == answer
+	}
+	if math.IsNaN(want) || math.IsNaN(got) || math.IsInf(want, 0) || math.IsInf(got, 0) {
+		return false
+	}
+	diff := math.Abs(got - want)
+	return diff <= absEps || diff <= relEps*math.Abs(want)
+}
+
+func truncateToken(token string) string {
+	const max = 32
+	if len(token) > max {
+		return token[:max] + "..."
+	}
+	return token
+}
+
+type customChecker struct {
+	runner   Judge
+	source   string
+	language Language
+}
+
+func (c customChecker) Check(ctx context.Context, input, output, answer string) (CheckResult, error) {
+	result, err := c.runner.Execute(ctx, ExecutionRequest{
+		SourceCode: c.source,
+		Language:   c.language,
+		Args:       []string{checkerInputFile, checkerOutputFile, checkerAnswerFile},
+		Files: map[string]string{
+			checkerInputFile:  input,
+			checkerOutputFile: output,
+			checkerAnswerFile: answer,
+		},
+	})
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	message := strings.TrimSpace(result.Stderr)
+	if message == "" {
+		message = strings.TrimSpace(result.Stdout)
+	}
+
+	switch {
+	case result.Status == StatusOK:
+		return CheckResult{Verdict: VerdictAccepted, Message: message}, nil
+	case result.Status == StatusRuntimeError && result.Signal == 0 && (result.ExitCode == 1 || result.ExitCode == 2):
+		return CheckResult{Verdict: VerdictWrongAnswer, Message: message}, nil
+	case result.Status == StatusCompileError:
+		return CheckResult{Verdict: VerdictInternalError, Message: "checker does not compile: " + result.CompileOutput}, nil
+	}
+	return CheckResult{Verdict: VerdictInternalError, Message: result.Description + ": " + message}, nil
+}
@@ -0,0 +1,72 @@
+package judge
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckers(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   CheckerSpec
+		output string
+		answer string
+		want   string
+	}{
+		{"exact match", CheckerSpec{}, "1 2\n", "1 2", VerdictAccepted},
+		{"exact crlf", CheckerSpec{Mode: CheckerExact}, "1\r\n2\r\n", "1\n2\n", VerdictAccepted},
+		{"exact inner spaces", CheckerSpec{Mode: CheckerExact}, "1  2", "1 2", VerdictWrongAnswer},
+		{"tokens spacing", CheckerSpec{Mode: CheckerTokens}, "1  2\n\n3", "1 2 3", VerdictAccepted},
+		{"tokens differ", CheckerSpec{Mode: CheckerTokens}, "1 2 4", "1 2 3", VerdictWrongAnswer},
+		{"tokens missing", CheckerSpec{Mode: CheckerTokens}, "1 2", "1 2 3", VerdictWrongAnswer},
+		{"tokens extra", CheckerSpec{Mode: CheckerTokens}, "1 2 3 4", "1 2 3", VerdictWrongAnswer},
+		{"case insensitive", CheckerSpec{Mode: CheckerCaseInsensitive}, "YES no", "yes NO", VerdictAccepted},
+		{"case insensitive differ", CheckerSpec{Mode: CheckerCaseInsensitive}, "yes", "no", VerdictWrongAnswer},
+		{"float default eps", CheckerSpec{Mode: CheckerFloat}, "0.3333333", "0.333333333", VerdictAccepted},
+		{"float too far", CheckerSpec{Mode: CheckerFloat}, "0.3334", "0.3333", VerdictWrongAnswer},
+		{"float absolute", CheckerSpec{Mode: CheckerFloat, AbsEps: 0.01}, "1.005", "1", VerdictAccepted},
+		{"float relative", CheckerSpec{Mode: CheckerFloat, RelEps: 1e-3}, "1000.5", "1000", VerdictAccepted},
+		{"float words", CheckerSpec{Mode: CheckerFloat}, "x 1.0", "x 1", VerdictAccepted},
+		{"float word differs", CheckerSpec{Mode: CheckerFloat}, "y 1", "x 1", VerdictWrongAnswer},
+		{"float nan", CheckerSpec{Mode: CheckerFloat}, "nan", "nan", VerdictWrongAnswer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewChecker(tt.spec)
+			if err != nil {
+				t.Fatalf("NewChecker: %v", err)
+			}
+			got, err := checker.Check(context.Background(), "", tt.output, tt.answer)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if got.Verdict != tt.want {
+				t.Errorf("verdict = %s (%s), want %s", got.Verdict, got.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCheckerInvalid(t *testing.T) {
+	if _, err := NewChecker(CheckerSpec{Mode: "fuzzy"}); err == nil {
+		t.Error("unknown mode: want an error")
+	}
+}
+
+func TestCheckerMessageHidesAnswer(t *testing.T) {
+	checker, err := NewChecker(CheckerSpec{Mode: CheckerTokens})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+	for _, output := range []string{"17 wrong", "17", "17 secret extra"} {
+		got, err := checker.Check(context.Background(), "", output, "17 secret")
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if got.Verdict != VerdictWrongAnswer || strings.Contains(got.Message, "secret") {
+			t.Errorf("output %q: verdict %s, message %q", output, got.Verdict, got.Message)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Stop policies decide when grading ends before the last test.
+const (
+	StopFirstFailure = "first_failure"
+	StopFirstTLE     = "first_tle"
+	StopNever        = "all"
+)
+
+func ValidStopPolicy(policy string) bool {
+	return policy == "" || policy == StopFirstFailure || policy == StopFirstTLE || policy == StopNever
+}
+
+// defaultStopPolicy keeps all-or-nothing problems quick and runs everything
+// when groups need every test for partial scores.
+func defaultStopPolicy(req GradeRequest) string {
+	if len(req.Groups) > 0 {
+		return StopNever
+	}
+	return StopFirstTLE
+}
+
+// stops reports whether a verdict ends grading under the policy. A
+// compilation error or a broken judge would repeat on every test, so those
+// always stop.
+func stops(policy, verdict string) bool {
+	switch verdict {
+	case VerdictAccepted:
+		return false
+	case VerdictCompileError, VerdictInternalError:
+		return true
+	}
+	switch policy {
+	case StopFirstFailure:
+		return true
+	case StopFirstTLE:
+		return verdict == VerdictTimeLimit
+	}
+	return false
+}
+
+type TestSpec struct {
+	ID      uint
+	GroupID uint // 0 when the problem has no groups
+	Input   string
+	Output  string
+}
+
+type GradeRequest struct {
+	SourceCode  string
+	Language    Language
+	TimeLimit   int // in milliseconds
+	MemoryLimit int // in KB
+	Tests       []TestSpec
+	Checker     Checker // nil for an exact comparison
+	Interactor  *InteractorSpec
+
+	Groups    []GroupSpec
+	FullScore float64 // awarded when there are no groups and every test passes
+
+	Parallelism int    // tests run at once, unless the judge runs batches
+	StopPolicy  string // empty for the default, see defaultStopPolicy
+}
+
+type TestResult struct {
+	TestCaseID uint
+	Verdict    string
+	Message    string           // from the checker, or why the run failed
+	Execution  *ExecutionResult // nil when the judge itself failed
+}
+
+type GradeResult struct {
+	Verdict       string // the first verdict that is not AC, in test order
+	CompileOutput string
+	Score         float64
+	GroupScores   []GroupScore
+	Results       []TestResult
+}
+
+// Grade runs the source against the tests and reports them in test order.
+// When the stop policy ends grading early, the cutoff is the first stopping
+// test in order, not the first to finish: every test before it is judged
+// and none after it is reported, so the result does not depend on
+// scheduling. Failures of the judge itself become IE verdicts, not errors,
+// so they are recorded like any other outcome.
+func Grade(ctx context.Context, j Judge, req GradeRequest) (*GradeResult, error) {
+	checker := req.Checker
+	if checker == nil {
+		checker = exactChecker{}
+	}
+	policy := req.StopPolicy
+	if policy == "" {
+		policy = defaultStopPolicy(req)
+	}
+
+	var outcomes []testOutcome
+	var cutoff int
+	if batcher, ok := j.(BatchJudge); ok && req.Interactor == nil {
+		outcomes, cutoff = gradeBatched(ctx, batcher, checker, policy, req)
+	} else {
+		outcomes, cutoff = gradeParallel(ctx, j, checker, policy, req)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if cutoff < len(outcomes) && outcomes[cutoff].err != nil {
+		return nil, outcomes[cutoff].err
+	}
+
+	last := min(cutoff, len(req.Tests)-1)
+	grade := &GradeResult{Verdict: VerdictAccepted}
+	for i := 0; i <= last; i++ {
+		o := outcomes[i]
+		if grade.Verdict == VerdictAccepted && o.check.Verdict != VerdictAccepted {
+			grade.Verdict = o.check.Verdict
+		}
+		if o.result != nil && o.result.CompileOutput != "" && grade.CompileOutput == "" {
+			grade.CompileOutput = o.result.CompileOutput
+		}
+		grade.Results = append(grade.Results, TestResult{
+			TestCaseID: req.Tests[i].ID,
+			Verdict:    o.check.Verdict,
+			Message:    o.check.Message,
+			Execution:  o.result,
+		})
+	}
+
+	grade.Score, grade.GroupScores = Score(req.Tests, grade.Results, req.Groups, req.FullScore)
+	return grade, nil
+}
+
+type testOutcome struct {
+	result *ExecutionResult
+	check  CheckResult
+	err    error // set only when the judge was unavailable
+}
+
+// judgeFailed reports a failure of the judge on the test it happened on.
+// An unavailable judge is kept as an error, and stops grading like IE.
+func judgeFailed(err error) testOutcome {
+	o := testOutcome{check: CheckResult{Verdict: VerdictInternalError, Message: err.Error()}}
+	if errors.Is(err, ErrJudgeUnavailable) {
+		o.err = err
+	}
+	return o
+}
+
+// gradeParallel runs up to Parallelism tests at once and returns the index
+// of the first test that stops grading, or len(tests).
+func gradeParallel(ctx context.Context, j Judge, checker Checker, policy string, req GradeRequest) ([]testOutcome, int) {
+	workers := min(max(req.Parallelism, 1), max(len(req.Tests), 1))
+	outcomes := make([]testOutcome, len(req.Tests))
+
+	var (
+		mu      sync.Mutex
+		next    int
+		cutoff  = len(req.Tests)
+		cancels = map[int]context.CancelFunc{}
+		wg      sync.WaitGroup
+	)
+
+	// take hands out the next test index, or -1 once none before the cutoff
+	// is left.
+	take := func() (int, context.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next >= cutoff || ctx.Err() != nil {
+			return -1, nil
+		}
+		i := next
+		next++
+		testCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		return i, testCtx
+	}
+
+	finish := func(i int, o testOutcome) {
+		mu.Lock()
+		defer mu.Unlock()
+		cancels[i]()
+		delete(cancels, i)
+		outcomes[i] = o
+		if i < cutoff && stops(policy, o.check.Verdict) {
+			cutoff = i
+			for k, cancel := range cancels {
+				if k > cutoff {
+					cancel()
+				}
+			}
+		}
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, testCtx := take()
+				if i < 0 {
+					return
+				}
+				test := req.Tests[i]
+
+				var o testOutcome
+				var err error
+				if req.Interactor != nil {
+					o.result, o.check, err = interact(testCtx, j, req, test)
+				} else {
+					o.result, o.check, err = runBatch(testCtx, j, checker, req, test)
+				}
+				if err != nil {
+					o = judgeFailed(err)
+				}
+				finish(i, o)
+			}
+		}()
+	}
+	wg.Wait()
+	return outcomes, cutoff
+}
+
+// gradeBatched sends the tests to the backend a batch at a time and checks
+// each batch in order, so an early stop saves the batches after it.
+func gradeBatched(ctx context.Context, j BatchJudge, checker Checker, policy string, req GradeRequest) ([]testOutcome, int) {
+	outcomes := make([]testOutcome, len(req.Tests))
+	size := max(j.BatchSize(), 1)
+
+	for start := 0; start < len(req.Tests); start += size {
+		tests := req.Tests[start:min(start+size, len(req.Tests))]
+		reqs := make([]ExecutionRequest, 0, len(tests))
+		for _, test := range tests {
+			reqs = append(reqs, executionRequest(req, test))
+		}
+
+		results, err := j.ExecuteBatch(ctx, reqs)
+		if err != nil {
+			outcomes[start] = judgeFailed(err)
+			return outcomes, start
+		}
+
+		for k, test := range tests {
+			i := start + k
+			check, err := checkExecution(ctx, checker, test, results[k])
+			if err != nil {
+				outcomes[i] = judgeFailed(err)
+				return outcomes, i
+			}
+			outcomes[i] = testOutcome{result: results[k], check: check}
+			if stops(policy, check.Verdict) {
+				return outcomes, i
+			}
+		}
+	}
+	return outcomes, len(req.Tests)
+}
+
+func executionRequest(req GradeRequest, test TestSpec) ExecutionRequest {
+	return ExecutionRequest{
+		SourceCode:  req.SourceCode,
+		Language:    req.Language,
+		Stdin:       test.Input,
+		TimeLimit:   req.TimeLimit,
+		MemoryLimit: req.MemoryLimit,
+	}
+}
+
+func runBatch(ctx context.Context, j Judge, checker Checker, req GradeRequest, test TestSpec) (*ExecutionResult, CheckResult, error) {
+	result, err := j.Execute(ctx, executionRequest(req, test))
+	if err != nil {
+		return nil, CheckResult{}, err
+	}
+	check, err := checkExecution(ctx, checker, test, result)
+	return result, check, err
+}
+
+func checkExecution(ctx context.Context, checker Checker, test TestSpec, result *ExecutionResult) (CheckResult, error) {
+	if verdict := executionVerdict(result.Status); verdict != "" {
+		return CheckResult{Verdict: verdict, Message: result.Description}, nil
+	}
+	return checker.Check(ctx, test.Input, result.Stdout, test.Output)
+}
@@ -0,0 +1,303 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeJudge answers each input with a canned result. A test's input is
+// "<outcome> <delay in ms>", where the outcome is ok (prints "ok"), wrong
+// (prints "no"), tle, re or down (the judge is unavailable).
+type fakeJudge struct {
+	running atomic.Int32
+	peak    atomic.Int32
+
+	mu  sync.Mutex
+	ran []string
+}
+
+func (f *fakeJudge) Name() string { return "fake" }
+
+func (f *fakeJudge) Languages(ctx context.Context) ([]BackendLanguage, error) { return nil, nil }
+
+func (f *fakeJudge) Execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	f.mu.Lock()
+	f.ran = append(f.ran, req.Stdin)
+	f.mu.Unlock()
+
+	var outcome string
+	var delay int
+	fmt.Sscan(req.Stdin, &outcome, &delay)
+	select {
+	case <-time.After(time.Duration(delay) * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return fakeResult(outcome)
+}
+
+func fakeResult(outcome string) (*ExecutionResult, error) {
+	switch outcome {
+	case "wrong":
+		return &ExecutionResult{Status: StatusOK, Stdout: "no"}, nil
+	case "tle":
+		return &ExecutionResult{Status: StatusTimeLimit, Description: "Time Limit Exceeded"}, nil
+	case "re":
+		return &ExecutionResult{Status: StatusRuntimeError, Description: "Runtime Error"}, nil
+	case "down":
+		return nil, fmt.Errorf("%w: no endpoint", ErrJudgeUnavailable)
+	}
+	return &ExecutionResult{Status: StatusOK, Stdout: "ok"}, nil
+}
+
+// fakeBatchJudge runs batches through the same canned results.
+type fakeBatchJudge struct {
+	fakeJudge
+	size    int
+	batches atomic.Int32
+}
+
+func (f *fakeBatchJudge) BatchSize() int { return f.size }
+
+func (f *fakeBatchJudge) ExecuteBatch(ctx context.Context, reqs []ExecutionRequest) ([]*ExecutionResult, error) {
+	f.batches.Add(1)
+	results := make([]*ExecutionResult, 0, len(reqs))
+	for _, req := range reqs {
+		result, err := fakeResult(strings.Fields(req.Stdin)[0])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func fakeTests(inputs ...string) []TestSpec {
+	tests := make([]TestSpec, 0, len(inputs))
+	for i, input := range inputs {
+		tests = append(tests, TestSpec{ID: uint(i + 1), Input: input, Output: "ok"})
+	}
+	return tests
+}
+
+func verdicts(grade *GradeResult) string {
+	parts := make([]string, 0, len(grade.Results))
+	for _, result := range grade.Results {
+		parts = append(parts, result.Verdict)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputs      []string
+		policy      string
+		parallelism int
+		verdict     string
+		results     string
+	}{
+		{
+			name:        "all accepted",
+			inputs:      []string{"ok 5", "ok 1", "ok 3", "ok 0"},
+			parallelism: 4,
+			verdict:     VerdictAccepted,
+			results:     "AC AC AC AC",
+		},
+		{
+			name:        "first failure stops at the failing test in order",
+			inputs:      []string{"ok 30", "wrong 30", "wrong 0", "ok 0"},
+			policy:      StopFirstFailure,
+			parallelism: 4,
+			verdict:     VerdictWrongAnswer,
+			results:     "AC WA",
+		},
+		{
+			name:        "a later failure that finishes first does not cut earlier tests",
+			inputs:      []string{"ok 40", "ok 40", "re 0", "ok 0"},
+			policy:      StopFirstFailure,
+			parallelism: 4,
+			verdict:     VerdictRuntimeError,
+			results:     "AC AC RE",
+		},
+		{
+			name:        "first tle runs past wrong answers",
+			inputs:      []string{"wrong 0", "ok 10", "tle 0", "ok 0"},
+			policy:      StopFirstTLE,
+			parallelism: 2,
+			verdict:     VerdictWrongAnswer,
+			results:     "WA AC TLE",
+		},
+		{
+			name:        "stop never reports every test",
+			inputs:      []string{"tle 0", "wrong 0", "ok 0"},
+			policy:      StopNever,
+			parallelism: 3,
+			verdict:     VerdictTimeLimit,
+			results:     "TLE WA AC",
+		},
+		{
+			name:        "sequential",
+			inputs:      []string{"ok 0", "wrong 0", "ok 0"},
+			policy:      StopFirstFailure,
+			parallelism: 1,
+			verdict:     VerdictWrongAnswer,
+			results:     "AC WA",
+		},
+		{
+			name:        "default policy without groups stops at tle",
+			inputs:      []string{"wrong 0", "tle 0", "ok 0"},
+			parallelism: 1,
+			verdict:     VerdictWrongAnswer,
+			results:     "WA TLE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Scheduling must not matter, so run each case a few times.
+			for run := 0; run < 5; run++ {
+				grade, err := Grade(context.Background(), &fakeJudge{}, GradeRequest{
+					Tests:       fakeTests(tt.inputs...),
+					FullScore:   100,
+					Parallelism: tt.parallelism,
+					StopPolicy:  tt.policy,
+				})
+				if err != nil {
+					t.Fatalf("Grade: %v", err)
+				}
+				if grade.Verdict != tt.verdict || verdicts(grade) != tt.results {
+					t.Fatalf("run %d: verdict %s with %q, want %s with %q", run, grade.Verdict, verdicts(grade), tt.verdict, tt.results)
+				}
+			}
+		})
+	}
+}
+
+func TestGradeParallelism(t *testing.T) {
+	inputs := make([]string, 12)
+	for i := range inputs {
+		inputs[i] = "ok 50"
+	}
+
+	for _, parallelism := range []int{0, 1, 3, 6} {
+		t.Run(fmt.Sprint(parallelism), func(t *testing.T) {
+			j := &fakeJudge{}
+			if _, err := Grade(context.Background(), j, GradeRequest{
+				Tests:       fakeTests(inputs...),
+				Parallelism: parallelism,
+			}); err != nil {
+				t.Fatalf("Grade: %v", err)
+			}
+			if want := int32(max(parallelism, 1)); j.peak.Load() != want {
+				t.Errorf("peak concurrency = %d, want %d", j.peak.Load(), want)
+			}
+		})
+	}
+}
+
+func TestGradeStopsStartingTests(t *testing.T) {
+	j := &fakeJudge{}
+	grade, err := Grade(context.Background(), j, GradeRequest{
+		Tests:       fakeTests("wrong 0", "ok 0", "ok 0", "ok 0", "ok 0", "ok 0"),
+		Parallelism: 1,
+		StopPolicy:  StopFirstFailure,
+	})
+	if err != nil {
+		t.Fatalf("Grade: %v", err)
+	}
+	if len(j.ran) != 1 || len(grade.Results) != 1 {
+		t.Errorf("ran %d tests and reported %d, want 1", len(j.ran), len(grade.Results))
+	}
+}
+
+func TestGradeUnavailable(t *testing.T) {
+	for _, batched := range []bool{false, true} {
+		t.Run(fmt.Sprint("batched ", batched), func(t *testing.T) {
+			var j Judge = &fakeJudge{}
+			if batched {
+				j = &fakeBatchJudge{size: 2}
+			}
+			_, err := Grade(context.Background(), j, GradeRequest{
+				Tests:       fakeTests("ok 0", "down 0", "ok 0"),
+				Parallelism: 2,
+			})
+			if !errors.Is(err, ErrJudgeUnavailable) {
+				t.Errorf("err = %v, want ErrJudgeUnavailable", err)
+			}
+		})
+	}
+}
+
+func TestGradeBatched(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		policy  string
+		verdict string
+		results string
+		batches int32
+	}{
+		{"all accepted", []string{"ok", "ok", "ok", "ok", "ok"}, StopFirstFailure, VerdictAccepted, "AC AC AC AC AC", 3},
+		{"stop saves later batches", []string{"ok", "wrong", "ok", "ok", "ok"}, StopFirstFailure, VerdictWrongAnswer, "AC WA", 1},
+		{"stop inside a batch", []string{"ok", "ok", "ok", "tle", "ok"}, StopFirstTLE, VerdictTimeLimit, "AC AC AC TLE", 2},
+		{"stop never", []string{"re", "ok", "wrong", "ok", "ok"}, StopNever, VerdictRuntimeError, "RE AC WA AC AC", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &fakeBatchJudge{size: 2}
+			grade, err := Grade(context.Background(), j, GradeRequest{
+				Tests:      fakeTests(tt.inputs...),
+				StopPolicy: tt.policy,
+			})
+			if err != nil {
+				t.Fatalf("Grade: %v", err)
+			}
+			if grade.Verdict != tt.verdict || verdicts(grade) != tt.results {
+				t.Errorf("verdict %s with %q, want %s with %q", grade.Verdict, verdicts(grade), tt.verdict, tt.results)
+			}
+			if j.batches.Load() != tt.batches {
+				t.Errorf("sent %d batches, want %d", j.batches.Load(), tt.batches)
+			}
+		})
+	}
+}
+
+func TestGradeScore(t *testing.T) {
+	grade, err := Grade(context.Background(), &fakeJudge{}, GradeRequest{
+		Tests: []TestSpec{
+			{ID: 1, GroupID: 1, Input: "ok 0", Output: "ok"},
+			{ID: 2, GroupID: 1, Input: "ok 0", Output: "ok"},
+			{ID: 3, GroupID: 2, Input: "wrong 0", Output: "ok"},
+			{ID: 4, GroupID: 2, Input: "ok 0", Output: "ok"},
+		},
+		Groups: []GroupSpec{
+			{ID: 1, Points: 30, Policy: ScoringMin},
+			{ID: 2, Points: 70, Policy: ScoringSum},
+		},
+		Parallelism: 4,
+	})
+	if err != nil {
+		t.Fatalf("Grade: %v", err)
+	}
+	// Groups default to running every test.
+	if len(grade.Results) != 4 || grade.Score != 65 {
+		t.Errorf("%d results scoring %v, want 4 scoring 65", len(grade.Results), grade.Score)
+	}
+}
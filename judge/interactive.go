@@ -0,0 +1,75 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Interactors follow the testlib convention: they are run as
+// `interactor input.txt output.txt answer.txt` with their stdin and stdout
+// connected to the contestant, and exit 0 for accepted, 1 for wrong answer,
+// 2 for presentation error and anything else when they fail themselves.
+const interactorOutputFile = "output.txt"
+
+var ErrInteractiveUnsupported = errors.New("judge backend does not support interactive problems")
+
+// InteractiveJudge is implemented by backends that can run two programs
+// talking to each other.
+type InteractiveJudge interface {
+	ExecuteInteractive(ctx context.Context, contestant, interactor ExecutionRequest) (*ExecutionResult, *ExecutionResult, error)
+}
+
+type InteractorSpec struct {
+	Source   string
+	Language Language
+}
+
+// interact runs one test of an interactive problem. Limits are only those of
+// the contestant; the interactor gets the backend defaults.
+func interact(ctx context.Context, j Judge, req GradeRequest, test TestSpec) (*ExecutionResult, CheckResult, error) {
+	ij, ok := j.(InteractiveJudge)
+	if !ok {
+		return nil, CheckResult{}, ErrInteractiveUnsupported
+	}
+
+	contestant, interactor, err := ij.ExecuteInteractive(ctx, ExecutionRequest{
+		SourceCode:  req.SourceCode,
+		Language:    req.Language,
+		TimeLimit:   req.TimeLimit,
+		MemoryLimit: req.MemoryLimit,
+	}, ExecutionRequest{
+		SourceCode: req.Interactor.Source,
+		Language:   req.Interactor.Language,
+		TimeLimit:  req.TimeLimit * 2,
+		Args:       []string{checkerInputFile, interactorOutputFile, checkerAnswerFile},
+		Files: map[string]string{
+			checkerInputFile:  test.Input,
+			checkerAnswerFile: test.Output,
+		},
+	})
+	if err != nil {
+		return nil, CheckResult{}, err
+	}
+
+	message := strings.TrimSpace(interactor.Stderr)
+
+	contestantVerdict := executionVerdict(contestant.Status)
+
+	switch {
+	case interactor.Status == StatusCompileError:
+		return contestant, CheckResult{Verdict: VerdictInternalError, Message: "interactor does not compile: " + interactor.CompileOutput}, nil
+	case contestantVerdict == VerdictCompileError, contestantVerdict == VerdictInternalError,
+		contestantVerdict == VerdictTimeLimit, contestantVerdict == VerdictMemoryLimit:
+		return contestant, CheckResult{Verdict: contestantVerdict, Message: contestant.Description}, nil
+	case interactor.Status == StatusRuntimeError && interactor.Signal == 0 && (interactor.ExitCode == 1 || interactor.ExitCode == 2):
+		// The contestant often crashes on a closed pipe after the
+		// interactor gives up, so the interactor's verdict wins.
+		return contestant, CheckResult{Verdict: VerdictWrongAnswer, Message: message}, nil
+	case contestantVerdict != "":
+		return contestant, CheckResult{Verdict: contestantVerdict, Message: contestant.Description}, nil
+	case interactor.Status != StatusOK:
+		return contestant, CheckResult{Verdict: VerdictInternalError, Message: interactor.Description + ": " + message}, nil
+	}
+	return contestant, CheckResult{Verdict: VerdictAccepted, Message: message}, nil
+}
@@ -0,0 +1,163 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+)
+
+// Execution statuses reported by a backend. They describe how the program
+// ran, not whether its output was correct; comparing output is up to the caller.
+const (
+	StatusOK            = "ok"
+	StatusTimeLimit     = "time_limit_exceeded"
+	StatusMemoryLimit   = "memory_limit_exceeded"
+	StatusRuntimeError  = "runtime_error"
+	StatusCompileError  = "compilation_error"
+	StatusInternalError = "internal_error"
+)
+
+var ErrLanguageNotFound = errors.New("language not found")
+
+// ErrJudgeUnavailable means no backend could take the request right now.
+// Grade returns it instead of an IE verdict so the submission can be judged
+// again later.
+var ErrJudgeUnavailable = errors.New("judge is unavailable")
+
+// Language is what a backend needs to know about a catalog language.
+// Backends fall back to their own defaults for empty fields.
+type Language struct {
+	Slug           string
+	JudgeID        int // backend specific id, e.g. Judge0's language_id
+	SourceFile     string
+	CompileCommand string
+	RunCommand     string
+}
+
+// BackendLanguage is a language offered by a backend, used by admins to
+// fill in the catalog.
+type BackendLanguage struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ExecutionRequest struct {
+	SourceCode string
+	Language   Language
+	Stdin      string
+
+	TimeLimit   int // in milliseconds, 0 for the backend default
+	MemoryLimit int // in KB, 0 for the backend default
+
+	// Only supported by the sandbox: extra files placed next to the program
+	// and arguments appended to its run command.
+	Files map[string]string
+	Args  []string
+}
+
+type ExecutionResult struct {
+	Status        string
+	Description   string // backend specific status text
+	Stdout        string
+	Stderr        string
+	CompileOutput string
+	ExitCode      int
+	Signal        int
+	Time          int // CPU time in milliseconds
+	WallTime      int // in milliseconds, 0 when the backend does not report it
+	Memory        int // in KB
+}
+
+// Judge runs a single program against a single input.
+type Judge interface {
+	Name() string
+	Languages(ctx context.Context) ([]BackendLanguage, error)
+	Execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error)
+}
+
+// BatchJudge is implemented by backends that run many requests in one round
+// trip. Grade sends batch problems to it BatchSize tests at a time.
+type BatchJudge interface {
+	Judge
+	BatchSize() int
+	ExecuteBatch(ctx context.Context, reqs []ExecutionRequest) ([]*ExecutionResult, error)
+}
+
+type Factory func(cfg config.JudgeConfig) (Judge, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+	current   Judge
+	checker   Judge
+)
+
+// Register makes a backend selectable through JUDGE_BACKEND.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+func New(cfg config.JudgeConfig) (Judge, error) {
+	mu.RLock()
+	factory, ok := factories[cfg.Backend]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown judge backend %q", cfg.Backend)
+	}
+	return factory(cfg)
+}
+
+func InitJudge() error {
+	cfg := config.LoadJudgeConfig()
+	j, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	SetJudge(j)
+
+	// Custom checkers always run locally, whatever judges the submissions.
+	if cfg.Backend == "sandbox" {
+		SetCheckerJudge(j)
+	} else {
+		cfg.Backend = "sandbox"
+		if sandbox, err := New(cfg); err == nil {
+			SetCheckerJudge(sandbox)
+		} else {
+			log.Println("Custom checkers are disabled:", err)
+		}
+	}
+	return nil
+}
+
+// SetJudge replaces the judge used by the handlers, e.g. with a fake in tests.
+func SetJudge(j Judge) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = j
+}
+
+func GetJudge() Judge {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+func SetCheckerJudge(j Judge) {
+	mu.Lock()
+	defer mu.Unlock()
+	checker = j
+}
+
+// GetCheckerJudge returns the backend that runs custom checkers, or nil when
+// none is available on this host.
+func GetCheckerJudge() Judge {
+	mu.RLock()
+	defer mu.RUnlock()
+	return checker
+}
@@ -0,0 +1,404 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+)
+
+// judge0BatchSize is Judge0's default MAX_SUBMISSION_BATCH_SIZE, which
+// bounds both creating and fetching a batch.
+const judge0BatchSize = 20
+
+const (
+	judge0PollStart = 200 * time.Millisecond
+	judge0PollMax   = 2 * time.Second
+	judge0RetryBase = 250 * time.Millisecond
+
+	// judge0PollFailures is how many polls in a row may fail before the
+	// tokens are given up on.
+	judge0PollFailures = 5
+)
+
+const judge0Fields = "stdout,stderr,compile_output,message,time,wall_time,memory,exit_code,exit_signal,status"
+
+func init() {
+	Register("judge0", func(cfg config.JudgeConfig) (Judge, error) {
+		return NewJudge0(cfg)
+	})
+}
+
+// Judge0 talks to one or more Judge0 CE instances, either hosted on RapidAPI
+// or self hosted.
+type Judge0 struct {
+	endpoints  []*judge0Endpoint
+	next       atomic.Uint32
+	apiKey     string
+	authHeader string
+	client     *http.Client
+
+	retries         int
+	breakerFailures int
+	breakerCooldown time.Duration
+}
+
+func NewJudge0(cfg config.JudgeConfig) (*Judge0, error) {
+	if len(cfg.Judge0URLs) == 0 {
+		return nil, errors.New("no judge0 url configured")
+	}
+
+	j := &Judge0{
+		apiKey:          cfg.Judge0Key,
+		authHeader:      cfg.Judge0Header,
+		client:          &http.Client{Timeout: cfg.Judge0Timeout},
+		retries:         max(cfg.Judge0Retries, 0),
+		breakerFailures: max(cfg.Judge0BreakerFailures, 1),
+		breakerCooldown: cfg.Judge0BreakerCooldown,
+	}
+	for _, baseURL := range cfg.Judge0URLs {
+		j.endpoints = append(j.endpoints, newJudge0Endpoint(baseURL, cfg.Judge0Host))
+	}
+	if cfg.Judge0HealthInterval > 0 {
+		go j.checkHealth(cfg.Judge0HealthInterval)
+	}
+	return j, nil
+}
+
+func (j *Judge0) Name() string {
+	return "judge0"
+}
+
+type judge0Submission struct {
+	SourceCode    string   `json:"source_code"`
+	LanguageID    int      `json:"language_id"`
+	Stdin         string   `json:"stdin"`
+	CPUTimeLimit  *float64 `json:"cpu_time_limit,omitempty"`
+	WallTimeLimit *float64 `json:"wall_time_limit,omitempty"`
+	MemoryLimit   *int     `json:"memory_limit,omitempty"`
+}
+
+type judge0Result struct {
+	Stdout        *string `json:"stdout"`
+	Stderr        *string `json:"stderr"`
+	CompileOutput *string `json:"compile_output"`
+	Message       *string `json:"message"`
+	Time          *string `json:"time"`
+	WallTime      *string `json:"wall_time"`
+	Memory        *int    `json:"memory"`
+	ExitCode      *int    `json:"exit_code"`
+	ExitSignal    *int    `json:"exit_signal"`
+	Status        struct {
+		ID          int    `json:"id"`
+		Description string `json:"description"`
+	} `json:"status"`
+}
+
+func (j *Judge0) newRequest(ctx context.Context, e *judge0Endpoint, method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if j.apiKey != "" {
+		req.Header.Add(j.authHeader, j.apiKey)
+	}
+	if e.host != "" {
+		req.Header.Add("x-rapidapi-host", e.host)
+	}
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func (j *Judge0) Languages(ctx context.Context) ([]BackendLanguage, error) {
+	var languages []BackendLanguage
+	err := j.withEndpoint(ctx, func(e *judge0Endpoint) error {
+		return j.do(ctx, e, http.MethodGet, "/languages", nil, &languages)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return languages, nil
+}
+
+// Execute runs a single request as a batch of one, so every run goes
+// through the same base64 encoded path.
+func (j *Judge0) Execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
+	results, err := j.ExecuteBatch(ctx, []ExecutionRequest{req})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (j *Judge0) BatchSize() int {
+	return judge0BatchSize
+}
+
+// ExecuteBatch submits the requests through the batch API and polls their
+// tokens until every one has finished. Results are in request order. A batch
+// that cannot be submitted goes to the next endpoint. Once it is, its tokens
+// only exist on the endpoint that issued them, so polling stays there.
+func (j *Judge0) ExecuteBatch(ctx context.Context, reqs []ExecutionRequest) ([]*ExecutionResult, error) {
+	payloads := make([]judge0Submission, 0, len(reqs))
+	for _, req := range reqs {
+		payload, err := newJudge0Submission(req)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+	}
+
+	var issuer *judge0Endpoint
+	var tokens []string
+	err := j.withEndpoint(ctx, func(e *judge0Endpoint) error {
+		tokens = make([]string, 0, len(payloads))
+		for start := 0; start < len(payloads); start += judge0BatchSize {
+			chunk, err := j.submitBatch(ctx, e, payloads[start:min(start+judge0BatchSize, len(payloads))])
+			if err != nil {
+				return err
+			}
+			tokens = append(tokens, chunk...)
+		}
+		issuer = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return j.await(ctx, issuer, tokens)
+}
+
+func newJudge0Submission(req ExecutionRequest) (judge0Submission, error) {
+	if req.Language.JudgeID == 0 {
+		return judge0Submission{}, fmt.Errorf("language %q has no judge0 id", req.Language.Slug)
+	}
+	if len(req.Files) > 0 || len(req.Args) > 0 {
+		return judge0Submission{}, errors.New("judge0 backend does not support extra files or arguments")
+	}
+
+	payload := judge0Submission{
+		SourceCode: base64.StdEncoding.EncodeToString([]byte(req.SourceCode)),
+		LanguageID: req.Language.JudgeID,
+		Stdin:      base64.StdEncoding.EncodeToString([]byte(req.Stdin)),
+	}
+	if req.TimeLimit > 0 {
+		cpu := float64(req.TimeLimit) / 1000
+		wall := cpu*2 + 1
+		payload.CPUTimeLimit = &cpu
+		payload.WallTimeLimit = &wall
+	}
+	if req.MemoryLimit > 0 {
+		payload.MemoryLimit = &req.MemoryLimit
+	}
+	return payload, nil
+}
+
+func (j *Judge0) submitBatch(ctx context.Context, e *judge0Endpoint, payloads []judge0Submission) ([]string, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{"submissions": payloads})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry is either a token or the validation errors of that
+	// submission.
+	var created []map[string]json.RawMessage
+	if err := j.do(ctx, e, http.MethodPost, "/submissions/batch?base64_encoded=true", jsonData, &created); err != nil {
+		return nil, err
+	}
+	if len(created) != len(payloads) {
+		return nil, fmt.Errorf("judge0 created %d of %d submissions", len(created), len(payloads))
+	}
+
+	tokens := make([]string, 0, len(created))
+	for _, entry := range created {
+		var token string
+		if raw, ok := entry["token"]; ok {
+			_ = json.Unmarshal(raw, &token)
+		}
+		if token == "" {
+			details, _ := json.Marshal(entry)
+			return nil, fmt.Errorf("judge0 rejected a submission: %s", details)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// await polls the tokens with exponential backoff until none is queued or
+// processing any more. A poll that fails transiently is tried again on the
+// next round, even while the endpoint's breaker is open, until
+// judge0PollFailures rounds in a row have failed.
+func (j *Judge0) await(ctx context.Context, e *judge0Endpoint, tokens []string) ([]*ExecutionResult, error) {
+	results := make([]*ExecutionResult, len(tokens))
+	pending := make([]int, len(tokens))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	delay := judge0PollStart
+	failures := 0
+	for {
+		var still []int
+		var pollErr error
+		for start := 0; start < len(pending); start += judge0BatchSize {
+			chunk := pending[start:min(start+judge0BatchSize, len(pending))]
+			if pollErr != nil {
+				still = append(still, chunk...)
+				continue
+			}
+			fetched, err := j.fetchBatch(ctx, e, tokens, chunk)
+			if err != nil {
+				if !transient(ctx, err) {
+					return nil, err
+				}
+				pollErr = err
+				still = append(still, chunk...)
+				continue
+			}
+			for k, i := range chunk {
+				result := fetched[k]
+				if result == nil || result.Status.ID == judge0InQueue || result.Status.ID == judge0Processing {
+					still = append(still, i)
+					continue
+				}
+				res, err := result.toExecutionResult()
+				if err != nil {
+					return nil, err
+				}
+				results[i] = res
+			}
+		}
+
+		if pollErr != nil {
+			failures++
+			if failures >= judge0PollFailures {
+				return nil, fmt.Errorf("%w: polling %s: %v", ErrJudgeUnavailable, e.baseURL, pollErr)
+			}
+		} else {
+			failures = 0
+		}
+
+		pending = still
+		if len(pending) == 0 {
+			return results, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*3/2, judge0PollMax)
+	}
+}
+
+func (j *Judge0) fetchBatch(ctx context.Context, e *judge0Endpoint, tokens []string, indices []int) ([]*judge0Result, error) {
+	batch := make([]string, 0, len(indices))
+	for _, i := range indices {
+		batch = append(batch, tokens[i])
+	}
+
+	path := "/submissions/batch?base64_encoded=true&fields=" + judge0Fields + "&tokens=" + url.QueryEscape(strings.Join(batch, ","))
+
+	var body struct {
+		Submissions []*judge0Result `json:"submissions"`
+	}
+	if err := j.do(ctx, e, http.MethodGet, path, nil, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Submissions) != len(indices) {
+		return nil, fmt.Errorf("judge0 returned %d of %d submissions", len(body.Submissions), len(indices))
+	}
+	return body.Submissions, nil
+}
+
+// toExecutionResult decodes the base64 fields. Output is kept byte for byte
+// even when it is not valid UTF-8; it is only sanitized when stored.
+func (r *judge0Result) toExecutionResult() (*ExecutionResult, error) {
+	var fields [4]string
+	for i, field := range []*string{r.Stdout, r.Stderr, r.CompileOutput, r.Message} {
+		decoded, err := decodeBase64(field)
+		if err != nil {
+			return nil, fmt.Errorf("judge0 returned invalid base64: %w", err)
+		}
+		fields[i] = decoded
+	}
+
+	res := &ExecutionResult{
+		Status:        judge0Status(r.Status.ID),
+		Description:   r.Status.Description,
+		Stdout:        fields[0],
+		Stderr:        fields[1],
+		CompileOutput: fields[2],
+	}
+	if res.Stderr == "" {
+		res.Stderr = fields[3]
+	}
+	res.Time = parseSeconds(r.Time)
+	res.WallTime = parseSeconds(r.WallTime)
+	if r.Memory != nil {
+		res.Memory = *r.Memory
+	}
+	if r.ExitCode != nil {
+		res.ExitCode = *r.ExitCode
+	}
+	if r.ExitSignal != nil {
+		res.Signal = *r.ExitSignal
+	}
+	return res, nil
+}
+
+const (
+	judge0InQueue    = 1
+	judge0Processing = 2
+)
+
+// judge0Status maps the ids from Judge0's /statuses endpoint. Without an
+// expected output Judge0 reports every clean run as "Accepted" (3).
+func judge0Status(id int) string {
+	switch id {
+	case 3, 4:
+		return StatusOK
+	case 5:
+		return StatusTimeLimit
+	case 6:
+		return StatusCompileError
+	case 7, 8, 9, 10, 11, 12:
+		return StatusRuntimeError
+	default:
+		return StatusInternalError
+	}
+}
+
+func parseSeconds(s *string) int {
+	if s == nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(*s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(seconds * 1000))
+}
+
+// decodeBase64 tolerates the line breaks Judge0 puts in long values.
+func decodeBase64(s *string) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\n", "", "\r", "").Replace(*s))
+	return string(decoded), err
+}
@@ -0,0 +1,230 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// judge0Endpoint is one Judge0 instance with its own circuit breaker. After
+// enough consecutive transient failures the breaker opens and the endpoint
+// is skipped until the cooldown ends. It is then half open: a single trial
+// request is let through, and its outcome closes the breaker or opens it for
+// another cooldown.
+type judge0Endpoint struct {
+	baseURL string
+	host    string // sent as x-rapidapi-host when set
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time // zero while the breaker is closed
+	trial     bool      // the half-open trial request is in flight
+	healthy   bool
+}
+
+func newJudge0Endpoint(baseURL, host string) *judge0Endpoint {
+	if host == "" {
+		if u, err := url.Parse(baseURL); err == nil && strings.HasSuffix(u.Host, ".rapidapi.com") {
+			host = u.Host
+		}
+	}
+	return &judge0Endpoint{baseURL: baseURL, host: host, healthy: true}
+}
+
+// available reports whether a request may go to the endpoint. When the
+// breaker is half open the first caller gets the trial and the others are
+// turned away until it is decided.
+func (e *judge0Endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case !e.healthy:
+		return false
+	case e.openUntil.IsZero():
+		return true
+	case now.Before(e.openUntil) || e.trial:
+		return false
+	}
+	e.trial = true
+	return true
+}
+
+func (e *judge0Endpoint) succeeded() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	e.openUntil = time.Time{}
+	e.trial = false
+}
+
+// failed records a transient failure and reports whether the breaker is
+// now open. A failed trial opens it again straight away.
+func (e *judge0Endpoint) failed(threshold int, cooldown time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.trial || e.failures >= threshold {
+		e.openUntil = time.Now().Add(cooldown)
+		e.trial = false
+		return true
+	}
+	return false
+}
+
+// release gives the trial back when its request ended without telling
+// whether the endpoint works, as when the caller cancelled it.
+func (e *judge0Endpoint) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.trial = false
+}
+
+func (e *judge0Endpoint) setHealthy(healthy bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.healthy = healthy
+}
+
+// judge0StatusError is a non-2xx response.
+type judge0StatusError struct {
+	code   int
+	status string
+}
+
+func (e *judge0StatusError) Error() string {
+	return "judge0 responded with " + e.status
+}
+
+// transient reports whether a failed request may succeed when retried, on
+// the same endpoint or another one. Cancellation by the caller is not.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *judge0StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// pick returns the next available endpoint not in tried, round robin.
+func (j *Judge0) pick(tried map[*judge0Endpoint]bool) *judge0Endpoint {
+	now := time.Now()
+	start := int(j.next.Add(1))
+	for k := 0; k < len(j.endpoints); k++ {
+		e := j.endpoints[(start+k)%len(j.endpoints)]
+		if !tried[e] && e.available(now) {
+			return e
+		}
+	}
+	return nil
+}
+
+// withEndpoint runs fn against available endpoints until one succeeds or
+// fails for a reason another endpoint would not fix. When none is left it
+// returns ErrJudgeUnavailable.
+func (j *Judge0) withEndpoint(ctx context.Context, fn func(e *judge0Endpoint) error) error {
+	tried := map[*judge0Endpoint]bool{}
+	var lastErr error
+	for {
+		e := j.pick(tried)
+		if e == nil {
+			if lastErr == nil {
+				return fmt.Errorf("%w: every judge0 endpoint is down", ErrJudgeUnavailable)
+			}
+			return fmt.Errorf("%w: %v", ErrJudgeUnavailable, lastErr)
+		}
+		tried[e] = true
+
+		err := fn(e)
+		if err == nil || !transient(ctx, err) {
+			return err
+		}
+		lastErr = err
+	}
+}
+
+// do sends a request to the endpoint, retrying transient failures with
+// jittered exponential backoff while the endpoint's breaker stays closed.
+func (j *Judge0) do(ctx context.Context, e *judge0Endpoint, method, path string, body []byte, out interface{}) error {
+	var err error
+	for attempt := 0; attempt <= j.retries; attempt++ {
+		if attempt > 0 {
+			backoff := judge0RetryBase << (attempt - 1)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff/2 + time.Duration(rand.Int63n(int64(backoff)))):
+			}
+		}
+
+		err = j.attempt(ctx, e, method, path, body, out)
+		if err == nil {
+			e.succeeded()
+			return nil
+		}
+		if !transient(ctx, err) {
+			// An error response still shows the endpoint is up.
+			var statusErr *judge0StatusError
+			if errors.As(err, &statusErr) {
+				e.succeeded()
+			} else {
+				e.release()
+			}
+			return err
+		}
+		if e.failed(j.breakerFailures, j.breakerCooldown) {
+			break
+		}
+	}
+	return err
+}
+
+func (j *Judge0) attempt(ctx context.Context, e *judge0Endpoint, method, path string, body []byte, out interface{}) error {
+	req, err := j.newRequest(ctx, e, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		io.Copy(io.Discard, resp.Body)
+		return &judge0StatusError{code: resp.StatusCode, status: resp.Status}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// checkHealth probes every endpoint on an interval so traffic only goes to
+// instances that answer. It runs for the life of the process.
+func (j *Judge0) checkHealth(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, e := range j.endpoints {
+			ctx, cancel := context.WithTimeout(context.Background(), interval/2)
+			err := j.attempt(ctx, e, http.MethodGet, "/about", nil, nil)
+			cancel()
+			e.setHealthy(err == nil)
+		}
+	}
+}
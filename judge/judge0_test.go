@@ -0,0 +1,393 @@
+package judge
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+)
+
+// fakeJudge0 implements the batch API. Each program prints its stdin in
+// upper case, after reporting "Processing" for the first pending polls.
+// Stdin "crash" ends in a runtime error and "reject" is refused on create.
+// While down every request fails with 503, and so do the first failPolls
+// polls.
+type fakeJudge0 struct {
+	*httptest.Server
+	pending   int
+	down      bool
+	failPolls int
+
+	mu      sync.Mutex
+	stdin   map[string]string
+	polled  map[string]int
+	submits int
+	polls   int
+}
+
+func newFakeJudge0(t *testing.T, pending int) *fakeJudge0 {
+	f := &fakeJudge0{pending: pending, stdin: map[string]string{}, polled: map[string]int{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeJudge0) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		if r.Method == http.MethodPost {
+			f.submits++
+		}
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/submissions/batch":
+		f.submits++
+		var body struct {
+			Submissions []judge0Submission `json:"submissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		created := make([]map[string]interface{}, 0, len(body.Submissions))
+		for _, s := range body.Submissions {
+			stdin, _ := base64.StdEncoding.DecodeString(s.Stdin)
+			if string(stdin) == "reject" {
+				created = append(created, map[string]interface{}{"language_id": []string{"is invalid"}})
+				continue
+			}
+			token := fmt.Sprint("t", len(f.stdin))
+			f.stdin[token] = string(stdin)
+			created = append(created, map[string]interface{}{"token": token})
+		}
+		json.NewEncoder(w).Encode(created)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/submissions/batch":
+		f.polls++
+		if f.polls <= f.failPolls {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Submissions []map[string]interface{} `json:"submissions"`
+		}
+		for _, token := range strings.Split(r.URL.Query().Get("tokens"), ",") {
+			f.polled[token]++
+			body.Submissions = append(body.Submissions, f.result(token))
+		}
+		json.NewEncoder(w).Encode(body)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeJudge0) result(token string) map[string]interface{} {
+	stdin, ok := f.stdin[token]
+	switch {
+	case !ok:
+		return nil
+	case f.polled[token] <= f.pending:
+		return map[string]interface{}{"status": map[string]interface{}{"id": judge0Processing, "description": "Processing"}}
+	case stdin == "crash":
+		return map[string]interface{}{
+			"stderr":    encodeLines("segfault"),
+			"exit_code": 139,
+			"status":    map[string]interface{}{"id": 11, "description": "Runtime Error (NZEC)"},
+		}
+	}
+	return map[string]interface{}{
+		"stdout": encodeLines(strings.ToUpper(stdin)),
+		"time":   "0.015",
+		"memory": 1024,
+		"status": map[string]interface{}{"id": 3, "description": "Accepted"},
+	}
+}
+
+// encodeLines encodes like Judge0 does, with a line break every 60
+// characters.
+func encodeLines(s string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	var lines []string
+	for len(encoded) > 60 {
+		lines = append(lines, encoded[:60])
+		encoded = encoded[60:]
+	}
+	return strings.Join(append(lines, encoded), "\n")
+}
+
+// newTestJudge0 does not retry, and opens a breaker on the first failure.
+func newTestJudge0(t *testing.T, urls ...string) *Judge0 {
+	j, err := NewJudge0(config.JudgeConfig{
+		Judge0URLs:            urls,
+		Judge0Timeout:         5 * time.Second,
+		Judge0BreakerFailures: 1,
+		Judge0BreakerCooldown: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewJudge0: %v", err)
+	}
+	return j
+}
+
+func judge0Requests(stdins ...string) []ExecutionRequest {
+	reqs := make([]ExecutionRequest, 0, len(stdins))
+	for _, stdin := range stdins {
+		reqs = append(reqs, ExecutionRequest{SourceCode: "code", Language: Language{Slug: "cpp", JudgeID: 54}, Stdin: stdin})
+	}
+	return reqs
+}
+
+func TestJudge0ExecuteBatch(t *testing.T) {
+	server := newFakeJudge0(t, 2)
+	j := newTestJudge0(t, server.URL)
+
+	// More than two batches, so creating and polling are both split.
+	stdins := make([]string, 45)
+	for i := range stdins {
+		stdins[i] = fmt.Sprint("case ", i, " ", strings.Repeat("x", i*3))
+	}
+	stdins[7] = "crash"
+
+	results, err := j.ExecuteBatch(context.Background(), judge0Requests(stdins...))
+	if err != nil {
+		t.Fatalf("ExecuteBatch: %v", err)
+	}
+	if len(results) != len(stdins) {
+		t.Fatalf("got %d results, want %d", len(results), len(stdins))
+	}
+	for i, res := range results {
+		if i == 7 {
+			if res.Status != StatusRuntimeError || res.Stderr != "segfault" || res.ExitCode != 139 {
+				t.Errorf("result 7 = %+v, want a runtime error", res)
+			}
+			continue
+		}
+		if res.Status != StatusOK || res.Stdout != strings.ToUpper(stdins[i]) || res.Time != 15 || res.Memory != 1024 {
+			t.Errorf("result %d = %+v", i, res)
+		}
+	}
+	// Three creates, then three rounds of three polls.
+	if server.submits != 3 || server.polls != 9 {
+		t.Errorf("%d creates and %d polls, want 3 and 9", server.submits, server.polls)
+	}
+}
+
+func TestJudge0Rejected(t *testing.T) {
+	server := newFakeJudge0(t, 0)
+	_, err := newTestJudge0(t, server.URL).ExecuteBatch(context.Background(), judge0Requests("a", "reject"))
+	if err == nil || !strings.Contains(err.Error(), "is invalid") {
+		t.Errorf("err = %v, want the validation error", err)
+	}
+}
+
+func TestJudge0Failover(t *testing.T) {
+	down, up := newFakeJudge0(t, 0), newFakeJudge0(t, 0)
+	down.down = true
+	j := newTestJudge0(t, down.URL, up.URL)
+
+	for i := 0; i < 4; i++ {
+		results, err := j.ExecuteBatch(context.Background(), judge0Requests("a", "b"))
+		if err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+		if results[1].Stdout != "B" {
+			t.Fatalf("run %d: stdout %q", i, results[1].Stdout)
+		}
+	}
+	// The open breaker keeps later runs away from the failed endpoint.
+	if down.submits > 1 || up.submits != 4 {
+		t.Errorf("down got %d submits and up %d, want at most 1 and 4", down.submits, up.submits)
+	}
+}
+
+func TestJudge0Unavailable(t *testing.T) {
+	first, second := newFakeJudge0(t, 0), newFakeJudge0(t, 0)
+	first.down, second.down = true, true
+	j := newTestJudge0(t, first.URL, second.URL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := j.Execute(context.Background(), judge0Requests("a")[0]); !errors.Is(err, ErrJudgeUnavailable) {
+			t.Errorf("run %d: err = %v, want ErrJudgeUnavailable", i, err)
+		}
+	}
+	if first.submits != 1 || second.submits != 1 {
+		t.Errorf("endpoints got %d and %d submits, want 1 each", first.submits, second.submits)
+	}
+}
+
+func TestJudge0PollRetry(t *testing.T) {
+	issuer, other := newFakeJudge0(t, 0), newFakeJudge0(t, 0)
+	issuer.failPolls = judge0PollFailures - 1
+	j := newTestJudge0(t, issuer.URL, other.URL)
+	j.next.Store(uint32(len(j.endpoints) - 1)) // the issuer is picked first
+
+	results, err := j.ExecuteBatch(context.Background(), judge0Requests("a"))
+	if err != nil {
+		t.Fatalf("ExecuteBatch: %v", err)
+	}
+	if results[0].Stdout != "A" {
+		t.Errorf("stdout %q, want A", results[0].Stdout)
+	}
+	// The tokens are only polled where they were issued, even though the
+	// first failure opened the breaker.
+	if issuer.submits != 1 || other.submits != 0 || issuer.polls != judge0PollFailures {
+		t.Errorf("issuer got %d submits and %d polls, other %d submits; want 1, %d and 0",
+			issuer.submits, issuer.polls, other.submits, judge0PollFailures)
+	}
+}
+
+func TestJudge0PollUnavailable(t *testing.T) {
+	issuer, other := newFakeJudge0(t, 0), newFakeJudge0(t, 0)
+	issuer.failPolls = judge0PollFailures
+	j := newTestJudge0(t, issuer.URL, other.URL)
+	j.next.Store(uint32(len(j.endpoints) - 1))
+
+	if _, err := j.ExecuteBatch(context.Background(), judge0Requests("a")); !errors.Is(err, ErrJudgeUnavailable) {
+		t.Errorf("err = %v, want ErrJudgeUnavailable", err)
+	}
+	if issuer.submits != 1 || other.submits != 0 {
+		t.Errorf("endpoints got %d and %d submits, want 1 and 0", issuer.submits, other.submits)
+	}
+}
+
+func TestJudge0Breaker(t *testing.T) {
+	e := newJudge0Endpoint("http://judge0", "")
+	now := time.Now()
+
+	if e.failed(2, time.Minute) || !e.available(now) {
+		t.Fatal("one failure opened the breaker")
+	}
+	if !e.failed(2, time.Minute) || e.available(now) {
+		t.Fatal("two failures left the breaker closed")
+	}
+	if !e.failed(2, time.Minute) || e.available(now) {
+		t.Fatal("breaker closed after another failure")
+	}
+
+	// Half open: one trial at a time, and a failed trial opens it again.
+	later := time.Now().Add(2 * time.Minute)
+	if !e.available(later) {
+		t.Fatal("no trial after the cooldown")
+	}
+	if e.available(later) {
+		t.Fatal("a second trial was let through")
+	}
+	if !e.failed(2, time.Minute) || e.available(time.Now()) {
+		t.Fatal("breaker not reopened by the failed trial")
+	}
+
+	// A released trial is handed out again.
+	later = time.Now().Add(2 * time.Minute)
+	if !e.available(later) {
+		t.Fatal("no trial after the second cooldown")
+	}
+	e.release()
+	if !e.available(later) {
+		t.Fatal("trial not handed out again after a release")
+	}
+
+	e.succeeded()
+	if !e.available(now) || !e.available(now) {
+		t.Fatal("breaker open after a successful trial")
+	}
+
+	e.setHealthy(false)
+	if e.available(now) {
+		t.Fatal("unhealthy endpoint is available")
+	}
+}
+
+func TestJudge0Result(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	result := func(id int, r judge0Result) judge0Result {
+		r.Status.ID = id
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		result  judge0Result
+		want    ExecutionResult
+		wantErr bool
+	}{
+		{
+			name:   "accepted",
+			result: result(3, judge0Result{Stdout: str("NDIK"), Time: str("0.25"), WallTime: str("0.5"), Memory: num(2048)}),
+			want:   ExecutionResult{Status: StatusOK, Stdout: "42\n", Time: 250, WallTime: 500, Memory: 2048},
+		},
+		{
+			name:   "line breaks in base64",
+			result: result(4, judge0Result{Stdout: str("aGVs\nbG8g\r\nd29y\nbGQ=\n")}),
+			want:   ExecutionResult{Status: StatusOK, Stdout: "hello world"},
+		},
+		{
+			name:   "invalid utf-8 kept",
+			result: result(3, judge0Result{Stdout: str(base64.StdEncoding.EncodeToString([]byte{0xff, 'a'}))}),
+			want:   ExecutionResult{Status: StatusOK, Stdout: "\xffa"},
+		},
+		{
+			name:   "time limit",
+			result: result(5, judge0Result{Time: str("1.001")}),
+			want:   ExecutionResult{Status: StatusTimeLimit, Time: 1001},
+		},
+		{
+			name:   "compile error",
+			result: result(6, judge0Result{CompileOutput: str("ZXJyb3I=")}),
+			want:   ExecutionResult{Status: StatusCompileError, CompileOutput: "error"},
+		},
+		{
+			name:   "signal",
+			result: result(11, judge0Result{ExitCode: num(0), ExitSignal: num(11)}),
+			want:   ExecutionResult{Status: StatusRuntimeError, Signal: 11},
+		},
+		{
+			name:   "message when stderr is empty",
+			result: result(13, judge0Result{Message: str("Ym9vbQ==")}),
+			want:   ExecutionResult{Status: StatusInternalError, Stderr: "boom"},
+		},
+		{
+			name:   "stderr wins over message",
+			result: result(12, judge0Result{Stderr: str("b29wcw=="), Message: str("Ym9vbQ==")}),
+			want:   ExecutionResult{Status: StatusRuntimeError, Stderr: "oops"},
+		},
+		{
+			name:    "invalid base64",
+			result:  result(3, judge0Result{Stdout: str("not base64!")}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.result.toExecutionResult()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toExecutionResult: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("result = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
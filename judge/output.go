@@ -0,0 +1,21 @@
+package judge
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncateOutput cuts s to at most limit bytes without splitting a UTF-8
+// sequence, and replaces NUL and invalid UTF-8, which Postgres rejects in
+// text columns and JSON cannot carry faithfully.
+func TruncateOutput(s string, limit int) string {
+	s = strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "\uFFFD")
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "\n... (truncated)"
+}
@@ -0,0 +1,97 @@
+package judge
+
+import (
+	"sort"
+	"strings"
+)
+
+// sandboxLanguage describes how the local sandbox builds and runs a language.
+// Commands are split on whitespace and run inside the submission directory.
+type sandboxLanguage struct {
+	SourceFile     string
+	CompileCommand string
+	RunCommand     string
+
+	// Managed runtimes reserve far more address space than they use, so they
+	// are limited by peak RSS only instead of RLIMIT_AS.
+	NoAddressLimit bool
+}
+
+var sandboxLanguages = map[string]sandboxLanguage{
+	"c": {
+		SourceFile:     "main.c",
+		CompileCommand: "gcc -O2 -std=c17 -o main main.c -lm",
+		RunCommand:     "./main",
+	},
+	"cpp": {
+		SourceFile:     "main.cpp",
+		CompileCommand: "g++ -O2 -std=c++17 -o main main.cpp",
+		RunCommand:     "./main",
+	},
+	"python": {
+		SourceFile: "main.py",
+		RunCommand: "python3 main.py",
+	},
+	"java": {
+		SourceFile:     "Main.java",
+		CompileCommand: "javac Main.java",
+		RunCommand:     "java -Xss64m -XX:+UseSerialGC -cp . Main",
+		NoAddressLimit: true,
+	},
+	"go": {
+		SourceFile:     "main.go",
+		CompileCommand: "go build -o main main.go",
+		RunCommand:     "./main",
+		NoAddressLimit: true,
+	},
+	"javascript": {
+		SourceFile:     "main.js",
+		RunCommand:     "node main.js",
+		NoAddressLimit: true,
+	},
+}
+
+var sandboxAliases = map[string]string{
+	"c++":     "cpp",
+	"python3": "python",
+	"py":      "python",
+	"golang":  "go",
+	"js":      "javascript",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+}
+
+// resolveSandboxLanguage starts from the built in definition for the slug,
+// if any, and applies the catalog's commands on top.
+func resolveSandboxLanguage(language Language) (sandboxLanguage, bool) {
+	name := strings.ToLower(strings.TrimSpace(language.Slug))
+	if alias, ok := sandboxAliases[name]; ok {
+		name = alias
+	}
+	lang := sandboxLanguages[name]
+
+	if language.SourceFile != "" {
+		lang.SourceFile = language.SourceFile
+	}
+	if language.CompileCommand != "" {
+		lang.CompileCommand = language.CompileCommand
+	}
+	if language.RunCommand != "" {
+		lang.RunCommand = language.RunCommand
+	}
+	return lang, lang.SourceFile != "" && lang.RunCommand != ""
+}
+
+func sandboxBackendLanguages() []BackendLanguage {
+	names := make([]string, 0, len(sandboxLanguages))
+	for name := range sandboxLanguages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	languages := make([]BackendLanguage, 0, len(names))
+	for _, name := range names {
+		languages = append(languages, BackendLanguage{Name: name})
+	}
+	return languages
+}
@@ -0,0 +1,310 @@
+package judge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sandboxInitArg      = "__contest_sandbox_init"
+	sandboxExecArg      = "__contest_sandbox_exec"
+	sandboxSpecEnv      = "CONTEST_SANDBOX_SPEC"
+	sandboxInitExitCode = 125
+	sandboxBox          = "/box" // where the run directory appears inside the sandbox
+
+	// Inherited descriptors: the init helper reports the submission's wait
+	// status on sandboxStatusFD, and hands the run's cgroup.procs to the
+	// exec stage on sandboxCgroupFD. Both are fd 3 of their own process.
+	sandboxStatusFD = 3
+	sandboxCgroupFD = 3
+)
+
+// sandboxSpec is handed from the server to the re-executed init process. The
+// init helper stays pid 1 of the run's namespaces and starts the exec stage,
+// which applies the rest to itself right before exec'ing the submission.
+type sandboxSpec struct {
+	Args []string `json:"args"`
+	Env  []string `json:"env"`
+
+	Root     string   `json:"root"`      // empty host directory the new root is built on
+	Dir      string   `json:"dir"`       // run directory, mounted read-write at sandboxBox
+	ReadOnly []string `json:"read_only"` // host paths mounted read-only
+	Cgroup   string   `json:"cgroup"`    // joined by the exec stage
+	UID      int      `json:"uid"`
+	GID      int      `json:"gid"`
+
+	CPUTime      int  `json:"cpu_time"`      // in milliseconds, 0 for no limit
+	AddressSpace int  `json:"address_space"` // in KB, 0 for no limit
+	Stack        int  `json:"stack"`         // in KB, 0 for no limit
+	FileSize     int  `json:"file_size"`     // in KB
+	OpenFiles    int  `json:"open_files"`
+	Seccomp      bool `json:"seccomp"`
+}
+
+// RunSandboxInit turns the current process into the sandbox init helper, or
+// its exec stage, when it was started by the sandbox backend. It must be the
+// first thing main does and never returns in that case.
+func RunSandboxInit() {
+	if len(os.Args) < 2 || (os.Args[1] != sandboxInitArg && os.Args[1] != sandboxExecArg) {
+		return
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		sandboxInitFail("invalid spec: %v", err)
+	}
+	if len(spec.Args) == 0 {
+		sandboxInitFail("no command")
+	}
+	if spec.UID <= 0 || spec.GID <= 0 {
+		sandboxInitFail("refusing to run as uid %d gid %d", spec.UID, spec.GID)
+	}
+
+	if os.Args[1] == sandboxInitArg {
+		spec.runInit()
+	}
+	spec.runExec()
+}
+
+// runInit runs as pid 1 of the new pid namespace. It builds the mounts, starts
+// the exec stage as its only child and reports how that ended. The submission
+// is never pid 1 itself, which would make it ignore its own signals and
+// SIGXCPU.
+func (spec sandboxSpec) runInit() {
+	// The submission must not be able to forge its own status.
+	syscall.CloseOnExec(sandboxStatusFD)
+	status := os.NewFile(sandboxStatusFD, "status")
+
+	if err := spec.isolate(); err != nil {
+		sandboxInitFail("%v", err)
+	}
+	procs, err := os.OpenFile(filepath.Join(spec.Cgroup, "cgroup.procs"), os.O_WRONLY, 0)
+	if err != nil {
+		sandboxInitFail("open cgroup: %v", err)
+	}
+
+	cmd := exec.Command("/proc/self/exe", sandboxExecArg)
+	cmd.Env = os.Environ()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{procs}
+	if err := cmd.Start(); err != nil {
+		sandboxInitFail("start: %v", err)
+	}
+	procs.Close()
+
+	// An exit error is the submission's own result, not the helper's.
+	cmd.Wait()
+	ws, _ := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if _, err := status.WriteString(strconv.FormatUint(uint64(ws), 10)); err != nil {
+		sandboxInitFail("report status: %v", err)
+	}
+	os.Exit(0)
+}
+
+// runExec confines the exec stage and replaces it with the submission.
+func (spec sandboxSpec) runExec() {
+	if err := spec.apply(); err != nil {
+		sandboxInitFail("%v", err)
+	}
+
+	path, err := exec.LookPath(spec.Args[0])
+	if err != nil {
+		sandboxInitFail("%v", err)
+	}
+	if err := syscall.Exec(path, spec.Args, spec.Env); err != nil {
+		sandboxInitFail("exec %s: %v", path, err)
+	}
+}
+
+func (spec sandboxSpec) apply() error {
+	if err := spec.pivot(); err != nil {
+		return err
+	}
+	if err := joinCgroup(); err != nil {
+		return err
+	}
+	if err := spec.dropPrivileges(); err != nil {
+		return err
+	}
+
+	limits := []rlimit{
+		{unix.RLIMIT_CORE, 0},
+		{unix.RLIMIT_FSIZE, uint64(spec.FileSize) * 1024},
+		{unix.RLIMIT_NOFILE, uint64(spec.OpenFiles)},
+	}
+	if spec.CPUTime > 0 {
+		// RLIMIT_CPU has a one second granularity, the exact limit is
+		// checked against rusage by the server afterwards.
+		limits = append(limits, rlimit{unix.RLIMIT_CPU, uint64(spec.CPUTime+999)/1000 + 1})
+	}
+	if spec.AddressSpace > 0 {
+		limits = append(limits, rlimit{unix.RLIMIT_AS, uint64(spec.AddressSpace) * 1024})
+	}
+	if spec.Stack > 0 {
+		limits = append(limits, rlimit{unix.RLIMIT_STACK, uint64(spec.Stack) * 1024})
+	}
+
+	for _, limit := range limits {
+		value := unix.Rlimit{Cur: limit.value, Max: limit.value}
+		if limit.resource == unix.RLIMIT_CPU {
+			// SIGXCPU at the soft limit, SIGKILL one second later.
+			value.Max = limit.value + 1
+		}
+		if err := unix.Setrlimit(limit.resource, &value); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", limit.resource, err)
+		}
+	}
+
+	if spec.Seccomp {
+		if err := installSeccomp(); err != nil {
+			return fmt.Errorf("seccomp: %w", err)
+		}
+	}
+	return nil
+}
+
+// isolate builds the new root on a tmpfs in the helper's own mount
+// namespace: the toolchains read-only, a few device nodes, the run directory
+// and a /proc for the helper's own pid namespace. The exec stage pivots into
+// it. Nothing else of the host,
+// the server's files and environment included, is visible from inside.
+func (spec sandboxSpec) isolate() error {
+	// Keep every mount below out of the host's namespace.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	if err := unix.Mount("tmpfs", spec.Root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+
+	for _, path := range spec.ReadOnly {
+		if err := bindMount(spec.Root, path, path, unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV); err != nil {
+			return err
+		}
+	}
+	for _, dev := range []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"} {
+		if err := bindMount(spec.Root, dev, dev, unix.MS_RDONLY|unix.MS_NOSUID); err != nil {
+			return err
+		}
+	}
+	if err := bindMount(spec.Root, spec.Dir, sandboxBox, unix.MS_NOSUID|unix.MS_NODEV); err != nil {
+		return err
+	}
+
+	proc := filepath.Join(spec.Root, "proc")
+	if err := os.Mkdir(proc, 0o555); err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	return nil
+}
+
+// bindMount mounts the host path source at target inside root with the given
+// flags. Missing sources are skipped and symlinks are copied, so the same
+// list works across distributions with and without a merged /usr.
+func bindMount(root, source, target string, flags uintptr) error {
+	info, err := os.Lstat(source)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(root, target)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	case info.IsDir():
+		err = os.Mkdir(dst, 0o755)
+	default:
+		err = os.WriteFile(dst, nil, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := unix.Mount(source, dst, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("mount %s: %w", source, err)
+	}
+	// Bind mounts take their flags only on a remount.
+	if err := unix.Mount("", dst, "", unix.MS_BIND|unix.MS_REMOUNT|flags, ""); err != nil {
+		return fmt.Errorf("remount %s: %w", source, err)
+	}
+	return nil
+}
+
+// joinCgroup moves the exec stage into the run's cgroup through the
+// cgroup.procs the init helper opened before the host's filesystem went
+// away. Memory touched before stays charged where it was, so the cgroup's
+// peak is the submission's own.
+func joinCgroup() error {
+	procs := os.NewFile(sandboxCgroupFD, "cgroup.procs")
+	defer procs.Close()
+	if _, err := procs.WriteString("0"); err != nil {
+		return fmt.Errorf("join cgroup: %w", err)
+	}
+	return nil
+}
+
+// pivot makes the tmpfs built by isolate the root, drops the host's root
+// and makes the new one read-only. The init helper shares the mount
+// namespace and moves along.
+func (spec sandboxSpec) pivot() error {
+	if err := os.Chdir(spec.Root); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	// The old root is stacked under the new one; detaching it leaves only
+	// the new root.
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root: %w", err)
+	}
+	return os.Chdir(sandboxBox)
+}
+
+// dropPrivileges switches to the sandbox user for good. The helpers start as
+// root only to set up the mounts and cgroup.
+func (spec sandboxSpec) dropPrivileges() error {
+	if err := syscall.Setgroups(nil); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setresgid(spec.GID, spec.GID, spec.GID); err != nil {
+		return fmt.Errorf("setresgid: %w", err)
+	}
+	if err := syscall.Setresuid(spec.UID, spec.UID, spec.UID); err != nil {
+		return fmt.Errorf("setresuid: %w", err)
+	}
+	return nil
+}
+
+type rlimit struct {
+	resource int
+	value    uint64
+}
+
+func sandboxInitFail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "sandbox: "+format+"\n", args...)
+	os.Exit(sandboxInitExitCode)
+}
@@ -0,0 +1,717 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"golang.org/x/sync/singleflight"
+)
+
+func init() {
+	Register("sandbox", func(cfg config.JudgeConfig) (Judge, error) {
+		return NewSandbox(cfg)
+	})
+}
+
+const (
+	sandboxDefaultTimeLimit   = 2000       // in milliseconds
+	sandboxDefaultMemoryLimit = 256 * 1024 // in KB
+	sandboxStackSlack         = 16 * 1024  // in KB, on top of the memory limit for RLIMIT_AS
+	sandboxOpenFiles          = 64
+	sandboxProcesses          = 64
+	sandboxBuildTTL           = 10 * time.Minute
+)
+
+// Sandbox compiles and runs submissions on the host. Each run happens in a
+// fresh directory, inside new network/ipc/uts/mount/pid namespaces with a
+// read-only root of its own, as a dedicated unprivileged user, in its own
+// cgroup, under rlimits and a seccomp filter that forbids sockets and
+// spawning processes.
+type Sandbox struct {
+	self          string
+	workDir       string
+	root          string
+	readOnly      []string
+	cgroup        string
+	runs          atomic.Uint64
+	uid, gid      int
+	compileTime   int
+	compileMemory int
+	outputLimit   int
+
+	compiling singleflight.Group
+	buildsMu  sync.Mutex
+	builds    map[string]*buildEntry
+}
+
+// sandboxRun is a single process started through the init helper.
+type sandboxRun struct {
+	Dir          string
+	Args         []string
+	Stdin        string
+	TimeLimit    int // CPU time in milliseconds
+	WallLimit    int // in milliseconds
+	MemoryLimit  int // peak RSS in KB
+	AddressSpace bool
+	Seccomp      bool
+	FileSize     int // in KB
+}
+
+func NewSandbox(cfg config.JudgeConfig) (*Sandbox, error) {
+	// Every run sets up its own mounts and cgroup before switching to the
+	// sandbox user, which takes root.
+	if os.Geteuid() != 0 {
+		return nil, errors.New("sandbox: the server must run as root to isolate submissions")
+	}
+	if cfg.SandboxUID <= 0 || cfg.SandboxGID <= 0 || cfg.SandboxUID == os.Getuid() || cfg.SandboxGID == os.Getgid() {
+		return nil, errors.New("sandbox: SANDBOX_UID and SANDBOX_GID must name a dedicated unprivileged user and group")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.SandboxWorkDir, 0o711); err != nil {
+		return nil, err
+	}
+	root := filepath.Join(cfg.SandboxWorkDir, "root")
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, err
+	}
+	if err := setupCgroup(cfg.SandboxCgroup); err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	return &Sandbox{
+		self:          self,
+		workDir:       cfg.SandboxWorkDir,
+		root:          root,
+		readOnly:      cfg.SandboxReadOnlyPaths,
+		cgroup:        cfg.SandboxCgroup,
+		uid:           cfg.SandboxUID,
+		gid:           cfg.SandboxGID,
+		compileTime:   cfg.SandboxCompileTime,
+		compileMemory: cfg.SandboxCompileMemory,
+		outputLimit:   cfg.SandboxOutputLimit,
+		builds:        map[string]*buildEntry{},
+	}, nil
+}
+
+func (s *Sandbox) Name() string {
+	return "sandbox"
+}
+
+// Languages lists the built in definitions; the sandbox has no language ids.
+func (s *Sandbox) Languages(ctx context.Context) ([]BackendLanguage, error) {
+	return sandboxBackendLanguages(), nil
+}
+
+func (s *Sandbox) Execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
+	run, failed, err := s.prepare(ctx, req)
+	if err != nil || failed != nil {
+		return failed, err
+	}
+	defer os.RemoveAll(run.Dir)
+
+	return s.run(ctx, run)
+}
+
+// prepare builds the program and lays out a fresh directory to run it in.
+// The caller removes run.Dir. A failed compilation is returned as the result.
+func (s *Sandbox) prepare(ctx context.Context, req ExecutionRequest) (sandboxRun, *ExecutionResult, error) {
+	lang, ok := resolveSandboxLanguage(req.Language)
+	if !ok {
+		return sandboxRun{}, nil, fmt.Errorf("%w: %q has no sandbox commands", ErrLanguageNotFound, req.Language.Slug)
+	}
+
+	var build string
+	if lang.CompileCommand != "" {
+		var failed *ExecutionResult
+		var err error
+		if build, failed, err = s.compile(ctx, lang, req.SourceCode); err != nil || failed != nil {
+			return sandboxRun{}, failed, err
+		}
+	}
+
+	dir, err := os.MkdirTemp(s.workDir, "run-*")
+	if err != nil {
+		return sandboxRun{}, nil, err
+	}
+	if err := s.populate(dir, build, lang, req); err != nil {
+		os.RemoveAll(dir)
+		return sandboxRun{}, nil, err
+	}
+
+	timeLimit := req.TimeLimit
+	if timeLimit <= 0 {
+		timeLimit = sandboxDefaultTimeLimit
+	}
+	memoryLimit := req.MemoryLimit
+	if memoryLimit <= 0 {
+		memoryLimit = sandboxDefaultMemoryLimit
+	}
+
+	return sandboxRun{
+		Dir:          dir,
+		Args:         append(strings.Fields(lang.RunCommand), req.Args...),
+		Stdin:        req.Stdin,
+		TimeLimit:    timeLimit,
+		WallLimit:    timeLimit*2 + 1000,
+		MemoryLimit:  memoryLimit,
+		AddressSpace: !lang.NoAddressLimit,
+		Seccomp:      true,
+		FileSize:     s.outputLimit,
+	}, nil, nil
+}
+
+func (s *Sandbox) populate(dir, build string, lang sandboxLanguage, req ExecutionRequest) error {
+	if build != "" {
+		if err := copyDir(build, dir); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(filepath.Join(dir, lang.SourceFile), []byte(req.SourceCode), 0o644); err != nil {
+		return err
+	}
+
+	for name, content := range req.Files {
+		if name != filepath.Base(name) || name == lang.SourceFile {
+			return fmt.Errorf("sandbox: invalid file name %q", name)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return s.chown(dir)
+}
+
+// compile builds the source once and keeps the build directory around, so
+// running it against every test (or a checker against every test) only
+// pays for the compiler the first time. A failed compilation is returned
+// as the result to report. The build is shared by every caller waiting on
+// the same source, so it runs on its own deadline rather than the first
+// caller's context; each caller stops waiting when its own ctx ends.
+func (s *Sandbox) compile(ctx context.Context, lang sandboxLanguage, source string) (string, *ExecutionResult, error) {
+	sum := sha256.Sum256([]byte(lang.CompileCommand + "\x00" + lang.SourceFile + "\x00" + source))
+	key := hex.EncodeToString(sum[:])
+
+	s.sweepBuilds()
+	if entry, ok := s.lookupBuild(key); ok {
+		return entry.dir, entry.failed, nil
+	}
+
+	results := s.compiling.DoChan(key, func() (interface{}, error) {
+		if entry, ok := s.lookupBuild(key); ok {
+			return entry, nil
+		}
+
+		dir := filepath.Join(s.workDir, "build-"+key[:32])
+		os.RemoveAll(dir)
+		if err := os.Mkdir(dir, 0o711); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, lang.SourceFile), []byte(source), 0o644); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		if err := s.chown(dir); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+
+		build := sandboxRun{
+			Dir:          dir,
+			Args:         strings.Fields(lang.CompileCommand),
+			TimeLimit:    s.compileTime,
+			WallLimit:    s.compileTime * 2,
+			MemoryLimit:  s.compileMemory,
+			AddressSpace: !lang.NoAddressLimit,
+			FileSize:     s.outputLimit,
+		}
+		// The wall limit ends a slow compiler first and reports it as
+		// such; the deadline only guards against a stuck sandbox.
+		buildCtx, cancel := context.WithTimeout(context.Background(), time.Duration(build.WallLimit+1000)*time.Millisecond)
+		defer cancel()
+		compiled, err := s.run(buildCtx, build)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+
+		entry := &buildEntry{dir: dir, lastUsed: time.Now()}
+		if compiled.Status != StatusOK {
+			compiled.CompileOutput = strings.TrimSpace(compiled.Stdout + "\n" + compiled.Stderr)
+			compiled.Stdout, compiled.Stderr = "", ""
+			if compiled.Status == StatusInternalError {
+				// Not cached, the next attempt may well succeed.
+				os.RemoveAll(dir)
+				return &buildEntry{failed: compiled}, nil
+			}
+			compiled.Status = StatusCompileError
+			compiled.Description = "Compilation Error"
+			entry.failed = compiled
+		}
+
+		s.buildsMu.Lock()
+		s.builds[key] = entry
+		s.buildsMu.Unlock()
+		return entry, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return "", nil, res.Err
+		}
+		entry := res.Val.(*buildEntry)
+		return entry.dir, entry.failed, nil
+	}
+}
+
+type buildEntry struct {
+	dir      string
+	failed   *ExecutionResult
+	lastUsed time.Time
+}
+
+func (s *Sandbox) lookupBuild(key string) (*buildEntry, bool) {
+	s.buildsMu.Lock()
+	defer s.buildsMu.Unlock()
+
+	entry, ok := s.builds[key]
+	if ok {
+		entry.lastUsed = time.Now()
+	}
+	return entry, ok
+}
+
+func (s *Sandbox) sweepBuilds() {
+	s.buildsMu.Lock()
+	defer s.buildsMu.Unlock()
+
+	for key, entry := range s.builds {
+		if time.Since(entry.lastUsed) > sandboxBuildTTL {
+			os.RemoveAll(entry.dir)
+			delete(s.builds, key)
+		}
+	}
+}
+
+func (s *Sandbox) chown(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, s.uid, s.gid)
+	})
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.Mkdir(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+func (s *Sandbox) run(ctx context.Context, run sandboxRun) (*ExecutionResult, error) {
+	stdout := &limitedBuffer{limit: s.outputLimit * 1024}
+	proc, err := s.start(ctx, run, strings.NewReader(run.Stdin), stdout)
+	if err != nil {
+		return nil, err
+	}
+	return proc.wait(ctx, stdout)
+}
+
+// sandboxProcess is a started run whose limits are being watched.
+type sandboxProcess struct {
+	run          sandboxRun
+	cmd          *exec.Cmd
+	status       *os.File // the submission's wait status, from the init helper
+	cgroup       string
+	stderr       *limitedBuffer
+	started      time.Time
+	wallExceeded atomic.Bool
+	timer        *time.Timer
+	stop         func() bool
+}
+
+// start launches the run with the given stdin and stdout, which may be pipes
+// to another sandboxed process.
+func (s *Sandbox) start(ctx context.Context, run sandboxRun, stdin io.Reader, stdout io.Writer) (*sandboxProcess, error) {
+	cgroup, err := s.newCgroup(run.MemoryLimit + sandboxStackSlack)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	spec := sandboxSpec{
+		Args:      run.Args,
+		Env:       []string{"PATH=" + os.Getenv("PATH"), "HOME=" + sandboxBox, "TMPDIR=" + sandboxBox, "LANG=C.UTF-8"},
+		Root:      s.root,
+		Dir:       run.Dir,
+		ReadOnly:  s.readOnly,
+		Cgroup:    cgroup,
+		UID:       s.uid,
+		GID:       s.gid,
+		CPUTime:   run.TimeLimit,
+		FileSize:  run.FileSize,
+		OpenFiles: sandboxOpenFiles,
+		Seccomp:   run.Seccomp,
+	}
+	if run.AddressSpace {
+		spec.AddressSpace = run.MemoryLimit + sandboxStackSlack
+	}
+	if run.Seccomp {
+		spec.Stack = run.MemoryLimit
+	}
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		os.Remove(cgroup)
+		return nil, err
+	}
+
+	proc := &sandboxProcess{
+		run:    run,
+		cgroup: cgroup,
+		stderr: &limitedBuffer{limit: s.outputLimit * 1024},
+	}
+
+	status, statusWriter, err := os.Pipe()
+	if err != nil {
+		os.Remove(cgroup)
+		return nil, err
+	}
+	proc.status = status
+
+	cmd := exec.Command(s.self, sandboxInitArg)
+	cmd.Dir = run.Dir
+	cmd.Env = append(spec.Env, sandboxSpecEnv+"="+string(encoded))
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = proc.stderr
+	cmd.ExtraFiles = []*os.File{statusWriter} // sandboxStatusFD
+	cmd.SysProcAttr = s.sysProcAttr()
+	proc.cmd = cmd
+
+	proc.started = time.Now()
+	err = cmd.Start()
+	statusWriter.Close()
+	if err != nil {
+		status.Close()
+		os.Remove(cgroup)
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	proc.timer = time.AfterFunc(time.Duration(run.WallLimit)*time.Millisecond, func() {
+		proc.wallExceeded.Store(true)
+		proc.kill()
+	})
+	proc.stop = context.AfterFunc(ctx, proc.kill)
+	return proc, nil
+}
+
+func (p *sandboxProcess) kill() {
+	syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// wait reaps the process and classifies how it ended. stdout is the buffer
+// given to start, or nil when it wrote to a pipe.
+func (p *sandboxProcess) wait(ctx context.Context, stdout *limitedBuffer) (*ExecutionResult, error) {
+	waitErr := p.cmd.Wait()
+	p.timer.Stop()
+	p.stop()
+	wall := int(time.Since(p.started).Milliseconds())
+	peak, peakErr := cgroupPeak(p.cgroup)
+	cpu, cpuErr := cgroupCPU(p.cgroup)
+	os.Remove(p.cgroup)
+	reported, _ := io.ReadAll(p.status)
+	p.status.Close()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if waitErr != nil {
+		if _, ok := waitErr.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("sandbox: %w", waitErr)
+		}
+	}
+
+	// The helper's own exit status only matters when it could not report
+	// the submission's: it failed to set up, or was killed.
+	state := p.cmd.ProcessState
+	usage, _ := state.SysUsage().(*syscall.Rusage)
+	status, _ := state.Sys().(syscall.WaitStatus)
+	if n, err := strconv.ParseUint(string(reported), 10, 32); err == nil {
+		status = syscall.WaitStatus(n)
+	}
+
+	res := &ExecutionResult{
+		Stderr:   p.stderr.String(),
+		ExitCode: status.ExitStatus(),
+		WallTime: wall,
+	}
+	overflow := p.stderr.overflow
+	if stdout != nil {
+		res.Stdout = stdout.String()
+		overflow = overflow || stdout.overflow
+	}
+	// The cgroup counts the submission alone, even when the helper was
+	// killed before it could reap it. The helper's rusage, which covers the
+	// children it waited for, is the fallback.
+	switch {
+	case cpuErr == nil:
+		res.Time = cpu
+	case usage != nil:
+		cpu := time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+		res.Time = int(cpu.Milliseconds())
+	}
+	// The rusage peak would include the init helper, which the cgroup
+	// leaves out.
+	if peakErr == nil {
+		res.Memory = peak
+	}
+	if status.Signaled() {
+		res.Signal = int(status.Signal())
+	}
+
+	switch {
+	case res.ExitCode == sandboxInitExitCode && strings.HasPrefix(res.Stderr, "sandbox: "):
+		res.Status, res.Description = StatusInternalError, strings.TrimSpace(res.Stderr)
+	case peakErr != nil:
+		res.Status, res.Description = StatusInternalError, "sandbox: "+peakErr.Error()
+	case p.wallExceeded.Load() || res.Time > p.run.TimeLimit || res.Signal == int(syscall.SIGXCPU):
+		res.Status, res.Description = StatusTimeLimit, "Time Limit Exceeded"
+	case res.Memory > p.run.MemoryLimit:
+		res.Status, res.Description = StatusMemoryLimit, "Memory Limit Exceeded"
+	case overflow || res.Signal == int(syscall.SIGXFSZ):
+		res.Status, res.Description = StatusRuntimeError, "Output Limit Exceeded"
+	case res.Signal != 0:
+		res.Status, res.Description = StatusRuntimeError, "Runtime Error ("+syscall.Signal(res.Signal).String()+")"
+	case res.ExitCode != 0:
+		res.Status, res.Description = StatusRuntimeError, fmt.Sprintf("Runtime Error (exit code %d)", res.ExitCode)
+	default:
+		res.Status, res.Description = StatusOK, "OK"
+	}
+	return res, nil
+}
+
+// sysProcAttr starts the init helper as root in namespaces of its own; it
+// builds the root and starts the submission under itself, which drops to the
+// sandbox user. In its own pid namespace the submission cannot see, or
+// signal, any other process.
+func (s *Sandbox) sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:    true,
+		Pdeathsig:  syscall.SIGKILL,
+		Cloneflags: syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID,
+	}
+}
+
+// setupCgroup creates the cgroup v2 directory runs are placed under and
+// hands the memory and pids controllers down to them.
+func setupCgroup(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+memory +pids"), 0); err != nil {
+		return fmt.Errorf("enable the memory and pids controllers in %s: %w", dir, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory.peak")); err != nil {
+		return fmt.Errorf("%s has no memory.peak, cgroup v2 on linux 5.19 or newer is required: %w", dir, err)
+	}
+	return nil
+}
+
+// newCgroup creates a cgroup for one run that caps memory at limit KB,
+// without swap, so going over is seen in its peak rather than hidden. The
+// process cap is per run; RLIMIT_NPROC would count every run of the shared
+// sandbox user together.
+func (s *Sandbox) newCgroup(limit int) (string, error) {
+	dir := filepath.Join(s.cgroup, fmt.Sprintf("run-%d-%d", os.Getpid(), s.runs.Add(1)))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", err
+	}
+	for file, value := range map[string]int{"memory.max": limit * 1024, "pids.max": sandboxProcesses} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(strconv.Itoa(value)), 0); err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	// Missing when the kernel has no swap accounting, then there is no
+	// swap to hide in either.
+	if err := os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		os.Remove(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// cgroupPeak reads the peak memory use of a run's cgroup in KB.
+func cgroupPeak(dir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "memory.peak"))
+	if err != nil {
+		return 0, err
+	}
+	peak, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, err
+	}
+	return peak / 1024, nil
+}
+
+// cgroupCPU reads the CPU time used in a run's cgroup in milliseconds.
+func cgroupCPU(dir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "usage_usec "); ok {
+			usec, err := strconv.Atoi(value)
+			if err != nil {
+				return 0, err
+			}
+			return usec / 1000, nil
+		}
+	}
+	return 0, errors.New("cpu.stat has no usage_usec")
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest,
+// so a runaway program cannot exhaust the server's memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.overflow = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// ExecuteInteractive runs the contestant and the interactor with each one's
+// stdout piped into the other's stdin.
+func (s *Sandbox) ExecuteInteractive(ctx context.Context, contestant, interactor ExecutionRequest) (*ExecutionResult, *ExecutionResult, error) {
+	interactorRun, failed, err := s.prepare(ctx, interactor)
+	if err != nil {
+		return nil, nil, err
+	}
+	if failed != nil {
+		return &ExecutionResult{Status: StatusOK}, failed, nil
+	}
+	defer os.RemoveAll(interactorRun.Dir)
+
+	contestantRun, failed, err := s.prepare(ctx, contestant)
+	if err != nil {
+		return nil, nil, err
+	}
+	if failed != nil {
+		return failed, &ExecutionResult{Status: StatusOK}, nil
+	}
+	defer os.RemoveAll(contestantRun.Dir)
+
+	// The interactor must outlive the contestant to report on it.
+	if interactorRun.WallLimit < contestantRun.WallLimit+1000 {
+		interactorRun.WallLimit = contestantRun.WallLimit + 1000
+	}
+
+	toInteractor, fromContestant, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	toContestant, fromInteractor, err := os.Pipe()
+	if err != nil {
+		toInteractor.Close()
+		fromContestant.Close()
+		return nil, nil, err
+	}
+
+	interactorProc, err := s.start(ctx, interactorRun, toInteractor, fromInteractor)
+	if err != nil {
+		closeAll(toInteractor, fromContestant, toContestant, fromInteractor)
+		return nil, nil, err
+	}
+	contestantProc, err := s.start(ctx, contestantRun, toContestant, fromContestant)
+	// The children hold their own copies now; closing ours lets each side
+	// see EOF as soon as the other exits.
+	closeAll(toInteractor, fromContestant, toContestant, fromInteractor)
+	if err != nil {
+		interactorProc.kill()
+		interactorProc.wait(ctx, nil)
+		return nil, nil, err
+	}
+
+	contestantResult, err := contestantProc.wait(ctx, nil)
+	if err != nil {
+		interactorProc.kill()
+		interactorProc.wait(ctx, nil)
+		return nil, nil, err
+	}
+	interactorResult, err := interactorProc.wait(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return contestantResult, interactorResult, nil
+}
+
+func closeAll(files ...*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package judge
+
+import (
+	"errors"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+)
+
+func init() {
+	Register("sandbox", func(cfg config.JudgeConfig) (Judge, error) {
+		return nil, errors.New("the sandbox judge backend is only supported on linux")
+	})
+}
+
+// RunSandboxInit is a no-op outside linux.
+func RunSandboxInit() {}
@@ -0,0 +1,114 @@
+package judge
+
+import "math"
+
+const (
+	ScoringMin = "min"
+	ScoringSum = "sum"
+)
+
+type GroupSpec struct {
+	ID           uint
+	Points       float64
+	Policy       string // min or sum
+	Dependencies []uint
+}
+
+type GroupScore struct {
+	GroupID uint
+	Score   float64
+	Full    bool
+}
+
+func ValidScoringPolicy(policy string) bool {
+	return policy == "" || policy == ScoringMin || policy == ScoringSum
+}
+
+// Score computes a submission's points. Without groups the problem is all or
+// nothing for fullScore. With groups, each group scores by its policy and
+// only when every group it depends on is fully solved. Tests that never ran
+// count as failed.
+func Score(tests []TestSpec, results []TestResult, groups []GroupSpec, fullScore float64) (float64, []GroupScore) {
+	passed := make(map[uint]bool, len(results))
+	for _, result := range results {
+		passed[result.TestCaseID] = result.Verdict == VerdictAccepted
+	}
+
+	if len(groups) == 0 {
+		for _, test := range tests {
+			if !passed[test.ID] {
+				return 0, nil
+			}
+		}
+		return fullScore, nil
+	}
+
+	total := map[uint]int{}
+	ok := map[uint]int{}
+	for _, test := range tests {
+		if test.GroupID == 0 {
+			continue
+		}
+		total[test.GroupID]++
+		if passed[test.ID] {
+			ok[test.GroupID]++
+		}
+	}
+
+	byID := make(map[uint]GroupSpec, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+
+	// full reports whether a group and, transitively, its dependencies are
+	// completely solved. A dependency cycle counts as unsolved.
+	state := map[uint]int{} // 0 unvisited, 1 visiting, 2 full, 3 not full
+	var full func(id uint) bool
+	full = func(id uint) bool {
+		switch state[id] {
+		case 1, 3:
+			return false
+		case 2:
+			return true
+		}
+		state[id] = 1
+		result := ok[id] == total[id]
+		for _, dep := range byID[id].Dependencies {
+			if !full(dep) {
+				result = false
+			}
+		}
+		if result {
+			state[id] = 2
+		} else {
+			state[id] = 3
+		}
+		return result
+	}
+
+	var score float64
+	scores := make([]GroupScore, 0, len(groups))
+	for _, group := range groups {
+		groupScore := GroupScore{GroupID: group.ID, Full: full(group.ID)}
+
+		depsFull := true
+		for _, dep := range group.Dependencies {
+			if !full(dep) {
+				depsFull = false
+			}
+		}
+
+		switch {
+		case !depsFull || total[group.ID] == 0:
+		case group.Policy == ScoringSum:
+			groupScore.Score = group.Points * float64(ok[group.ID]) / float64(total[group.ID])
+		case groupScore.Full:
+			groupScore.Score = group.Points
+		}
+
+		groupScore.Score = math.Round(groupScore.Score*100) / 100
+		score += groupScore.Score
+		scores = append(scores, groupScore)
+	}
+	return score, scores
+}
@@ -0,0 +1,188 @@
+package judge
+
+import "testing"
+
+// results marks the listed tests accepted and every other one wrong.
+func results(tests []TestSpec, accepted ...uint) []TestResult {
+	ok := map[uint]bool{}
+	for _, id := range accepted {
+		ok[id] = true
+	}
+	out := make([]TestResult, 0, len(tests))
+	for _, test := range tests {
+		verdict := VerdictWrongAnswer
+		if ok[test.ID] {
+			verdict = VerdictAccepted
+		}
+		out = append(out, TestResult{TestCaseID: test.ID, Verdict: verdict})
+	}
+	return out
+}
+
+func TestScore(t *testing.T) {
+	ungrouped := []TestSpec{{ID: 1}, {ID: 2}, {ID: 3}}
+	grouped := []TestSpec{
+		{ID: 1, GroupID: 1}, {ID: 2, GroupID: 1},
+		{ID: 3, GroupID: 2}, {ID: 4, GroupID: 2}, {ID: 5, GroupID: 2}, {ID: 6, GroupID: 2},
+		{ID: 7, GroupID: 3},
+	}
+
+	tests := []struct {
+		name       string
+		tests      []TestSpec
+		results    []TestResult
+		groups     []GroupSpec
+		want       float64
+		wantGroups []GroupScore
+	}{
+		{
+			name:    "all or nothing solved",
+			tests:   ungrouped,
+			results: results(ungrouped, 1, 2, 3),
+			want:    100,
+		},
+		{
+			name:    "all or nothing failed",
+			tests:   ungrouped,
+			results: results(ungrouped, 1, 3),
+			want:    0,
+		},
+		{
+			name:    "all or nothing with a test that never ran",
+			tests:   ungrouped,
+			results: results(ungrouped[:2], 1, 2),
+			want:    0,
+		},
+		{
+			name:    "min group needs every test",
+			tests:   grouped,
+			results: results(grouped, 1, 3, 4, 5, 6, 7),
+			groups: []GroupSpec{
+				{ID: 1, Points: 20, Policy: ScoringMin},
+				{ID: 2, Points: 40, Policy: ScoringMin},
+				{ID: 3, Points: 40, Policy: ScoringMin},
+			},
+			want: 80,
+			wantGroups: []GroupScore{
+				{GroupID: 1, Score: 0},
+				{GroupID: 2, Score: 40, Full: true},
+				{GroupID: 3, Score: 40, Full: true},
+			},
+		},
+		{
+			name:    "sum group is proportional",
+			tests:   grouped,
+			results: results(grouped, 3, 4, 5),
+			groups: []GroupSpec{
+				{ID: 2, Points: 40, Policy: ScoringSum},
+			},
+			want:       30,
+			wantGroups: []GroupScore{{GroupID: 2, Score: 30}},
+		},
+		{
+			name:    "sum group rounds to hundredths",
+			tests:   grouped,
+			results: results(grouped, 1),
+			groups: []GroupSpec{
+				{ID: 1, Points: 33.333, Policy: ScoringSum},
+			},
+			want:       16.67,
+			wantGroups: []GroupScore{{GroupID: 1, Score: 16.67}},
+		},
+		{
+			name:    "dependency not full scores nothing",
+			tests:   grouped,
+			results: results(grouped, 2, 3, 4, 5, 6, 7),
+			groups: []GroupSpec{
+				{ID: 1, Points: 20, Policy: ScoringMin},
+				{ID: 2, Points: 40, Policy: ScoringSum, Dependencies: []uint{1}},
+				{ID: 3, Points: 40, Policy: ScoringMin},
+			},
+			want: 40,
+			wantGroups: []GroupScore{
+				{GroupID: 1, Score: 0},
+				{GroupID: 2, Score: 0},
+				{GroupID: 3, Score: 40, Full: true},
+			},
+		},
+		{
+			name:    "transitive dependency",
+			tests:   grouped,
+			results: results(grouped, 2, 3, 4, 5, 6, 7),
+			groups: []GroupSpec{
+				{ID: 1, Points: 20, Policy: ScoringMin},
+				{ID: 2, Points: 40, Policy: ScoringMin, Dependencies: []uint{1}},
+				{ID: 3, Points: 40, Policy: ScoringMin, Dependencies: []uint{2}},
+			},
+			want: 0,
+			wantGroups: []GroupScore{
+				{GroupID: 1, Score: 0},
+				{GroupID: 2, Score: 0},
+				{GroupID: 3, Score: 0, Full: false},
+			},
+		},
+		{
+			name:    "dependencies met",
+			tests:   grouped,
+			results: results(grouped, 1, 2, 3, 4, 7),
+			groups: []GroupSpec{
+				{ID: 1, Points: 20, Policy: ScoringMin},
+				{ID: 2, Points: 40, Policy: ScoringSum, Dependencies: []uint{1}},
+				{ID: 3, Points: 40, Policy: ScoringMin, Dependencies: []uint{1}},
+			},
+			want: 80,
+			wantGroups: []GroupScore{
+				{GroupID: 1, Score: 20, Full: true},
+				{GroupID: 2, Score: 20},
+				{GroupID: 3, Score: 40, Full: true},
+			},
+		},
+		{
+			name:    "dependency cycle scores nothing",
+			tests:   grouped,
+			results: results(grouped, 1, 2, 3, 4, 5, 6, 7),
+			groups: []GroupSpec{
+				{ID: 1, Points: 20, Policy: ScoringMin, Dependencies: []uint{2}},
+				{ID: 2, Points: 40, Policy: ScoringMin, Dependencies: []uint{1}},
+				{ID: 3, Points: 40, Policy: ScoringMin},
+			},
+			want: 40,
+			wantGroups: []GroupScore{
+				{GroupID: 1, Score: 0},
+				{GroupID: 2, Score: 0},
+				{GroupID: 3, Score: 40, Full: true},
+			},
+		},
+		{
+			name:    "empty group scores nothing",
+			tests:   grouped,
+			results: results(grouped, 1, 2),
+			groups: []GroupSpec{
+				{ID: 1, Points: 20, Policy: ScoringMin},
+				{ID: 9, Points: 80, Policy: ScoringSum},
+			},
+			want: 20,
+			wantGroups: []GroupScore{
+				{GroupID: 1, Score: 20, Full: true},
+				{GroupID: 9, Score: 0, Full: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, groups := Score(tt.tests, tt.results, tt.groups, 100)
+			if got != tt.want {
+				t.Errorf("score = %v, want %v", got, tt.want)
+			}
+			if len(groups) != len(tt.wantGroups) {
+				t.Fatalf("group scores = %+v, want %+v", groups, tt.wantGroups)
+			}
+			for i := range groups {
+				if groups[i] != tt.wantGroups[i] {
+					t.Errorf("group %d = %+v, want %+v", i, groups[i], tt.wantGroups[i])
+				}
+			}
+		})
+	}
+}
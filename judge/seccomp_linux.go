@@ -0,0 +1,82 @@
+//go:build linux && (amd64 || arm64)
+
+package judge
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const seccompDataArgsOffset = 16 // offsetof(struct seccomp_data, args)
+
+// seccompSignals take the target process (or, for tkill, thread) as their
+// first argument. Runtimes signal themselves (abort, raise, Go's and the
+// JVM's thread preemption), so only signals to any other process are refused.
+var seccompSignals = []int{unix.SYS_KILL, unix.SYS_TKILL, unix.SYS_TGKILL}
+
+// installSeccomp loads a deny list filter on the calling thread. The thread
+// must stay locked until it execs the submission, which inherits the filter
+// and keeps the pid. Threads are allowed (clone with CLONE_THREAD), new
+// processes are not.
+func installSeccomp() error {
+	runtime.LockOSThread()
+	// pid_t is an int; the kernel ignores the upper half of the argument.
+	self := uint32(os.Getpid())
+
+	errno := func(e unix.Errno) uint32 {
+		return unix.SECCOMP_RET_ERRNO | uint32(e)
+	}
+
+	filter := []unix.SockFilter{
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 4), // arch
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, seccompArch, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_KILL_PROCESS),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0), // syscall number
+	}
+	for _, nr := range seccompDenied {
+		filter = append(filter,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, errno(unix.EPERM)),
+		)
+	}
+	for _, nr := range seccompSignals {
+		filter = append(filter,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 4),
+			bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArgsOffset),
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, self, 1, 0),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, errno(unix.EPERM)),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+		)
+	}
+	filter = append(filter,
+		// clone3 passes its flags in memory where BPF cannot see them;
+		// ENOSYS makes libc fall back to clone.
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE3, 0, 1),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, errno(unix.ENOSYS)),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE, 0, 3),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArgsOffset),
+		bpfJump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, unix.CLONE_THREAD, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, errno(unix.EPERM)),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+	)
+
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+	return unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0)
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
@@ -0,0 +1,40 @@
+package judge
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_X86_64
+
+var seccompDenied = []int{
+	unix.SYS_FORK,
+	unix.SYS_VFORK,
+	unix.SYS_SOCKET,
+	unix.SYS_SOCKETPAIR,
+	unix.SYS_CONNECT,
+	unix.SYS_BIND,
+	unix.SYS_LISTEN,
+	unix.SYS_ACCEPT,
+	unix.SYS_ACCEPT4,
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_CHROOT,
+	unix.SYS_UNSHARE,
+	unix.SYS_SETNS,
+	unix.SYS_SETUID,
+	unix.SYS_SETGID,
+	unix.SYS_SETREUID,
+	unix.SYS_SETREGID,
+	unix.SYS_SETRESUID,
+	unix.SYS_SETRESGID,
+	unix.SYS_REBOOT,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_USERFAULTFD,
+}
@@ -0,0 +1,30 @@
+package judge
+
+// Verdicts stored per test and per submission.
+const (
+	VerdictAccepted      = "AC"
+	VerdictWrongAnswer   = "WA"
+	VerdictTimeLimit     = "TLE"
+	VerdictMemoryLimit   = "MLE"
+	VerdictRuntimeError  = "RE"
+	VerdictCompileError  = "CE"
+	VerdictInternalError = "IE"
+)
+
+// executionVerdict is the verdict for a run that did not finish cleanly, or
+// "" when the output still has to be checked.
+func executionVerdict(status string) string {
+	switch status {
+	case StatusOK:
+		return ""
+	case StatusTimeLimit:
+		return VerdictTimeLimit
+	case StatusMemoryLimit:
+		return VerdictMemoryLimit
+	case StatusRuntimeError:
+		return VerdictRuntimeError
+	case StatusCompileError:
+		return VerdictCompileError
+	}
+	return VerdictInternalError
+}
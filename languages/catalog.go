@@ -0,0 +1,115 @@
+package languages
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/models"
+)
+
+// cacheTTL bounds how long an instance serves a catalog another instance
+// has already changed.
+const cacheTTL = time.Minute
+
+var ErrNotFound = errors.New("language is not supported")
+
+var (
+	mu       sync.RWMutex
+	cache    map[string]models.Language
+	loadedAt time.Time
+)
+
+func load() (map[string]models.Language, error) {
+	mu.RLock()
+	if cache != nil && time.Since(loadedAt) < cacheTTL {
+		defer mu.RUnlock()
+		return cache, nil
+	}
+	mu.RUnlock()
+
+	var languages []models.Language
+	if err := config.GetDB().Find(&languages).Error; err != nil {
+		return nil, err
+	}
+
+	fresh := make(map[string]models.Language, len(languages))
+	for _, language := range languages {
+		fresh[language.Slug] = language
+	}
+
+	mu.Lock()
+	cache, loadedAt = fresh, time.Now()
+	mu.Unlock()
+	return fresh, nil
+}
+
+// Get returns an enabled language by its exact slug.
+func Get(slug string) (*models.Language, error) {
+	language, err := Find(slug)
+	if err != nil {
+		return nil, err
+	}
+	if !language.Enabled {
+		return nil, ErrNotFound
+	}
+	return language, nil
+}
+
+// Find returns a language whether or not it is enabled, so submissions queued
+// before an admin disabled it can still be judged.
+func Find(slug string) (*models.Language, error) {
+	languages, err := load()
+	if err != nil {
+		return nil, err
+	}
+	language, ok := languages[Normalize(slug)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &language, nil
+}
+
+// Enabled lists the languages contestants may submit in.
+func Enabled() ([]models.Language, error) {
+	languages, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := []models.Language{}
+	for _, language := range languages {
+		if language.Enabled {
+			enabled = append(enabled, language)
+		}
+	}
+	sort.Slice(enabled, func(i, j int) bool {
+		return enabled[i].Name < enabled[j].Name
+	})
+	return enabled, nil
+}
+
+// Invalidate drops the cache after the catalog changes.
+func Invalidate() {
+	mu.Lock()
+	cache = nil
+	mu.Unlock()
+}
+
+func Normalize(slug string) string {
+	return strings.ToLower(strings.TrimSpace(slug))
+}
+
+func ToJudge(language *models.Language) judge.Language {
+	return judge.Language{
+		Slug:           language.Slug,
+		JudgeID:        language.JudgeID,
+		SourceFile:     language.SourceFile,
+		CompileCommand: language.CompileCommand,
+		RunCommand:     language.RunCommand,
+	}
+}
@@ -0,0 +1,57 @@
+package languages
+
+import (
+	"math"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+)
+
+// Limits are the effective limits of a problem for one language.
+type Limits struct {
+	TimeLimit   int // in milliseconds
+	MemoryLimit int // in MB
+}
+
+// EffectiveLimits scales the problem's limits for the language. override may
+// be nil.
+func EffectiveLimits(problem *models.Problem, language *models.Language, override *models.ProblemLanguageLimit) Limits {
+	multiplier, timeOffset, memoryOffset := language.TimeMultiplier, language.TimeOffset, language.MemoryOffset
+	if override != nil {
+		if override.TimeMultiplier != nil {
+			multiplier = *override.TimeMultiplier
+		}
+		if override.TimeOffset != nil {
+			timeOffset = *override.TimeOffset
+		}
+		if override.MemoryOffset != nil {
+			memoryOffset = *override.MemoryOffset
+		}
+	}
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+
+	return Limits{
+		TimeLimit:   max(int(math.Round(float64(problem.TimeLimit)*multiplier))+timeOffset, 1),
+		MemoryLimit: max(problem.MemoryLimit+memoryOffset, 1),
+	}
+}
+
+// ProblemLimits loads the problem's override for the language, if any, and
+// returns the effective limits.
+func ProblemLimits(problem *models.Problem, language *models.Language) (Limits, error) {
+	var overrides []models.ProblemLanguageLimit
+	if err := config.GetDB().
+		Where("problem_id = ? AND language = ?", problem.ID, language.Slug).
+		Limit(1).
+		Find(&overrides).Error; err != nil {
+		return Limits{}, err
+	}
+
+	var override *models.ProblemLanguageLimit
+	if len(overrides) > 0 {
+		override = &overrides[0]
+	}
+	return EffectiveLimits(problem, language, override), nil
+}
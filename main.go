@@ -5,11 +5,19 @@ import (
 
 	"github.com/ankush-web-eng/contest-backend/config"
 	"github.com/ankush-web-eng/contest-backend/handler"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/migrations"
+	"github.com/ankush-web-eng/contest-backend/plagiarism"
+	"github.com/ankush-web-eng/contest-backend/queue"
+	"github.com/ankush-web-eng/contest-backend/scheduler"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	judge.RunSandboxInit()
+	config.LoadEnv()
+
 	r := gin.Default()
 
 	config.InitDB()
@@ -20,9 +28,35 @@ func main() {
 	// 	&models.TestCase{},
 	// 	&models.Submission{},
 	// 	&models.UserContest{},
-	// 	&models.RatingChange{}); err != nil {
+	// 	&models.RatingChange{},
+	// 	&models.Language{},
+	// 	&models.TestGroup{},
+	// 	&models.UserProblemScore{},
+	// 	&models.SubmissionTestResult{},
+	// 	&models.SubmissionVerdictHistory{},
+	// 	&models.PlagiarismReport{},
+	// 	&models.PlagiarismPair{},
+	// 	&models.PlagiarismMatch{},
+	// 	&models.ReferenceSolution{},
+	// 	&models.ProblemValidation{},
+	// 	&models.ReferenceSolutionResult{},
+	// 	&models.ProblemLanguageLimit{},
+	// 	&models.VirtualParticipation{},
+	// 	&models.StandingsReveal{},
+	// 	&models.StandingsSnapshot{},
+	// 	&models.CodeRun{},
+	// 	&models.Migration{}); err != nil {
 	// 	panic("Failed to migrate database: " + err.Error())
 	// }
+	if err := migrations.Run(); err != nil {
+		panic("Failed to migrate database: " + err.Error())
+	}
+	if err := judge.InitJudge(); err != nil {
+		panic("Failed to initialize judge: " + err.Error())
+	}
+	queue.Start(config.LoadQueueConfig())
+	plagiarism.Start()
+	scheduler.Start(config.LoadSchedulerConfig())
 	// gin.SetMode(gin.ReleaseMode)
 
 	r.Use(cors.New(cors.Config{
@@ -39,6 +73,9 @@ func main() {
 	handler.RegisterContestRoutes(r)
 	handler.RegisterCodeRoutes(r)
 	handler.RegisterLiveRoutes(r)
+	handler.RegisterLanguageRoutes(r)
+	handler.RegisterPlagiarismRoutes(r)
+	handler.RegisterProblemRoutes(r)
 	if err := r.Run(":8080"); err != nil {
 		panic("Failed to start server: " + err.Error())
 	}
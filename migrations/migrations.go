@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type migration struct {
+	id  string
+	run func(tx *gorm.DB) error
+}
+
+// migrations fill in data the schema alone does not give existing
+// deployments. They run in order, each exactly once; append new ones at the
+// end and never rename an id.
+var migrations = []migration{
+	{"seed-languages", seedLanguages},
+	{"publish-existing-problems", publishExistingProblems},
+}
+
+// Run applies the migrations this database has not seen yet. Each one commits
+// together with its row in migrations, so an instance starting at the same
+// time waits on that row and then skips it.
+func Run() error {
+	db := config.GetDB()
+	if err := db.AutoMigrate(&models.Migration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Migration{ID: m.id})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			log.Println("Running migration", m.id)
+			return m.run(tx)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.id, err)
+		}
+	}
+	return nil
+}
+
+// seedLanguages adds the languages submissions could use before the catalog
+// existed, with their Judge0 CE ids. The sandbox backend has built-in
+// commands for the same slugs. Languages an admin already added are left
+// alone.
+func seedLanguages(tx *gorm.DB) error {
+	seed := []models.Language{
+		{Slug: "c", Name: "C", Version: "GCC 9.2.0", JudgeID: 50},
+		{Slug: "cpp", Name: "C++", Version: "GCC 9.2.0", JudgeID: 54},
+		{Slug: "java", Name: "Java", Version: "OpenJDK 13.0.1", JudgeID: 62},
+		{Slug: "python", Name: "Python", Version: "3.8.1", JudgeID: 71},
+		{Slug: "javascript", Name: "JavaScript", Version: "Node.js 12.14.0", JudgeID: 63},
+		{Slug: "go", Name: "Go", Version: "1.13.5", JudgeID: 60},
+	}
+	for i := range seed {
+		seed[i].TimeMultiplier = 1
+		seed[i].Enabled = true
+	}
+	return tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slug"}}, DoNothing: true}).Create(&seed).Error
+}
+
+// publishExistingProblems keeps the problems that were visible before
+// publishing needed a validation visible.
+func publishExistingProblems(tx *gorm.DB) error {
+	return tx.Model(&models.Problem{}).Where("is_published = ?", false).Update("is_published", true).Error
+}
@@ -52,17 +52,36 @@ type Contest struct {
 	EndTime     time.Time `gorm:"not null;index"`
 
 	IsPublic    bool `gorm:"default:true"`
-	MaxDuration int  // in minutes, go for 0 for no limit
+	MaxDuration int  // in minutes; above 0 every participant starts their own window of this length
 	CreatorID   uint `gorm:"not null"`
 
-	Status      string `gorm:"default:'pending'"` // pending, active, completed
-	RatingFloor int
-	RatingCeil  int
+	Status      string `gorm:"default:'pending';index"` // one of the Contest* statuses, moved on by the scheduler
+	RatingFloor int    // 0 for no floor
+	RatingCeil  int    // 0 for no ceiling
+
+	RegistrationDeadline *time.Time // nil keeps registration open until StartTime
+	MaxParticipants      int        // 0 for no limit
 
 	IsRated       bool   `gorm:"default:true"`
 	RatingType    string `gorm:"default:'standard'"` // standard, performance, random
 	RatingKFactor int    `gorm:"default:32"`         // Rating change magnitude factor
 
+	StopPolicy string // first_failure, first_tle or all; empty picks by problem
+
+	ScoringMode    string `gorm:"default:'ioi'"` // icpc or ioi
+	PenaltyMinutes int    // per wrong attempt on a solved problem, icpc only
+
+	// The public standings hide results submitted in the last FreezeMinutes
+	// until the resolver has revealed them all.
+	FreezeMinutes int // 0 for no freeze
+	UnfrozenAt    *time.Time
+
+	RatedAt *time.Time // set once ratings have been calculated
+
+	// Set when a judged submission changes the official results; the
+	// scheduler then refreshes the ranks stored on UserContest.
+	StandingsStale bool `gorm:"default:false"`
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
@@ -72,6 +91,12 @@ type Contest struct {
 	Creator  User      `gorm:"foreignKey:CreatorID"`
 }
 
+const (
+	ContestPending   = "pending"
+	ContestActive    = "active"
+	ContestCompleted = "completed"
+)
+
 type RatingChange struct {
 	ID          uint      `gorm:"primaryKey"`
 	UserID      uint      `gorm:"not null;index"`
@@ -88,13 +113,14 @@ type RatingChange struct {
 }
 
 type UserContest struct {
-	UserID    uint `gorm:"primaryKey"`
-	ContestID uint `gorm:"primaryKey"`
-	Score     float64
-	Rank      int
+	UserID    uint    `gorm:"primaryKey"`
+	ContestID uint    `gorm:"primaryKey"`
+	Score     float64 // problems solved in icpc mode, points in ioi mode
+	Penalty   int     // in minutes, icpc mode only
+	Rank      int     // 0 until the user is on the standings
 	StartTime time.Time
 	EndTime   time.Time
-	Status    string // registered, started, finished
+	Status    string // one of the Registration* statuses
 
 	InitialRating int
 	RatingChange  int
@@ -109,6 +135,50 @@ type UserContest struct {
 	Contest Contest
 }
 
+// StandingsSnapshot is the public standings of one cohort as of the last
+// refresh, encoded as JSON.
+type StandingsSnapshot struct {
+	ContestID uint   `gorm:"primaryKey"`
+	Cohort    string `gorm:"primaryKey"`
+	Board     string `gorm:"type:jsonb;not null"`
+
+	UpdatedAt time.Time
+}
+
+// StandingsReveal is a frozen cell of the standings that the resolver has
+// shown to the public.
+type StandingsReveal struct {
+	ID        uint `gorm:"primaryKey"`
+	ContestID uint `gorm:"not null;uniqueIndex:idx_standings_reveal"`
+	UserID    uint `gorm:"not null;uniqueIndex:idx_standings_reveal"`
+	ProblemID uint `gorm:"not null;uniqueIndex:idx_standings_reveal"`
+
+	CreatedAt time.Time
+}
+
+// VirtualParticipation is a rerun of a finished contest in a window of its
+// own. It is never rated.
+type VirtualParticipation struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	ContestID uint      `gorm:"not null;index"`
+	StartTime time.Time `gorm:"not null"`
+	EndTime   time.Time `gorm:"not null"`
+	Score     float64
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	User User
+}
+
+const (
+	RegistrationRegistered   = "registered"
+	RegistrationStarted      = "started"
+	RegistrationFinished     = "finished"
+	RegistrationDisqualified = "disqualified"
+)
+
 type Problem struct {
 	ID          uint   `gorm:"primaryKey"`
 	ContestID   uint   `gorm:"not null;index"`
@@ -125,6 +195,21 @@ type Problem struct {
 	SampleOutput   string
 	TestCasesCount int `gorm:"not null"`
 
+	CheckerMode     string  `gorm:"default:'exact'"` // exact, tokens, float, case_insensitive, custom
+	CheckerAbsEps   float64 // float mode, 0 for both means 1e-6
+	CheckerRelEps   float64
+	CheckerSource   string // custom mode, run as `checker input output answer`
+	CheckerLanguage string // language slug of CheckerSource
+
+	InteractorSource   string // set for interactive problems, which ignore the checker
+	InteractorLanguage string
+
+	StopPolicy string // overrides the contest's when set
+
+	// Contestants only see published problems. Publishing needs a passing
+	// validation against the reference solutions.
+	IsPublished bool `gorm:"default:false;index"`
+
 	TotalSubmissions      int
 	SuccessfulSubmissions int
 
@@ -135,6 +220,26 @@ type Problem struct {
 	Contest     Contest      `gorm:"foreignKey:ContestID"`
 	Submissions []Submission `gorm:"constraint:OnDelete:CASCADE;"`
 	TestCases   []TestCase   `gorm:"constraint:OnDelete:CASCADE;"`
+	TestGroups  []TestGroup  `gorm:"constraint:OnDelete:CASCADE;"`
+
+	ReferenceSolutions []ReferenceSolution    `gorm:"constraint:OnDelete:CASCADE;"`
+	Validations        []ProblemValidation    `gorm:"constraint:OnDelete:CASCADE;"`
+	LanguageLimits     []ProblemLanguageLimit `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// TestGroup is a subtask. A problem without groups is scored all or nothing.
+type TestGroup struct {
+	ID            uint    `gorm:"primaryKey"`
+	ProblemID     uint    `gorm:"not null;index"`
+	Name          string  `gorm:"not null"`
+	Points        float64 `gorm:"not null"`
+	ScoringPolicy string  `gorm:"default:'min'"` // min: all tests or nothing, sum: proportional to passed tests
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Groups that must be fully solved for this one to score at all.
+	Dependencies []TestGroup `gorm:"many2many:test_group_dependencies;"`
 }
 
 type TestCase struct {
@@ -145,6 +250,7 @@ type TestCase struct {
 	IsHidden    bool   `gorm:"default:true"`
 	TimeLimit   int    // in milliseconds
 	MemoryLimit int    // in KB
+	GroupID     *uint  `gorm:"index"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -152,16 +258,38 @@ type TestCase struct {
 	Problem Problem
 }
 
+const (
+	ParticipationContest  = "contest"
+	ParticipationPractice = "practice"
+	ParticipationVirtual  = "virtual"
+)
+
 type Submission struct {
 	ID        uint   `gorm:"primaryKey"`
 	UserID    uint   `gorm:"not null;index"`
 	ProblemID uint   `gorm:"not null;index"`
+	ContestID uint   `gorm:"index"`
 	Language  string `gorm:"not null"`
 	Code      string `gorm:"not null"`
-	Status    string `gorm:"not null"` // accepted, wrong_answer, time_limit_exceeded, etc.
+	Status    string `gorm:"not null"` // queued, judging, or a verdict: AC, WA, TLE, MLE, RE, CE, IE
 	Score     float64
-	Runtime   int // in milliseconds
-	Memory    int // in KB
+	Runtime   int // in milliseconds, the slowest test
+	Memory    int // in KB, the peak over all tests
+
+	// ParticipationContest inside the user's contest window,
+	// ParticipationVirtual inside a virtual run after the contest, and
+	// ParticipationPractice otherwise. Only contest submissions count towards
+	// the contest score and rating.
+	ParticipationType      string `gorm:"default:'contest';index"`
+	VirtualParticipationID *uint  `gorm:"index"`
+
+	// Set once the submission has been added to the problem and user
+	// statistics, so rejudges do not count it again.
+	StatsCounted bool `gorm:"default:false"`
+
+	CompileOutput string
+	TestResults   []SubmissionTestResult     `gorm:"constraint:OnDelete:CASCADE;"`
+	History       []SubmissionVerdictHistory `gorm:"constraint:OnDelete:CASCADE;"`
 
 	SubmittedAt time.Time `gorm:"not null;index"`
 	CreatedAt   time.Time
@@ -170,3 +298,189 @@ type Submission struct {
 	User    User    `gorm:"foreignKey:UserID"`
 	Problem Problem `gorm:"foreignKey:ProblemID"`
 }
+
+// CodeRun records a custom run. Runs leave no submission behind, so this is
+// what their rate limit counts.
+type CodeRun struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index:idx_code_run_user"`
+	ProblemID uint      `gorm:"not null"`
+	RunAt     time.Time `gorm:"not null;index:idx_code_run_user"`
+}
+
+// ProblemLanguageLimit overrides a language's multiplier and offsets on one
+// problem. Nil fields keep the language's value.
+type ProblemLanguageLimit struct {
+	ID             uint   `gorm:"primaryKey"`
+	ProblemID      uint   `gorm:"not null;uniqueIndex:idx_problem_language"`
+	Language       string `gorm:"not null;uniqueIndex:idx_problem_language"`
+	TimeMultiplier *float64
+	TimeOffset     *int
+	MemoryOffset   *int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ReferenceSolution is a setter's solution with the verdict it must get on
+// the problem's tests, used to validate the test data.
+type ReferenceSolution struct {
+	ID              uint   `gorm:"primaryKey"`
+	ProblemID       uint   `gorm:"not null;index"`
+	Name            string `gorm:"not null"`
+	Language        string `gorm:"not null"`
+	Source          string `gorm:"not null"`
+	ExpectedVerdict string `gorm:"not null"` // AC, WA or TLE
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ProblemValidation is one run of every reference solution on every test.
+type ProblemValidation struct {
+	ID        uint   `gorm:"primaryKey"`
+	ProblemID uint   `gorm:"not null;index"`
+	Status    string `gorm:"not null"` // running, passed, failed
+	Message   string
+	CreatedBy uint
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Results []ReferenceSolutionResult `gorm:"foreignKey:ValidationID;constraint:OnDelete:CASCADE;"`
+}
+
+type ReferenceSolutionResult struct {
+	ID                  uint `gorm:"primaryKey"`
+	ValidationID        uint `gorm:"not null;index"`
+	ReferenceSolutionID uint `gorm:"not null;index"`
+	ExpectedVerdict     string
+	Verdict             string // overall, the first verdict that is not AC
+	Passed              bool
+	FailedTestCaseID    *uint // the test that broke the expectation, if one did
+	MaxTime             int   // in milliseconds
+	Message             string
+}
+
+// SubmissionVerdictHistory keeps the outcome a submission had before an
+// admin rejudged it.
+type SubmissionVerdictHistory struct {
+	ID           uint   `gorm:"primaryKey"`
+	SubmissionID uint   `gorm:"not null;index"`
+	Status       string `gorm:"not null"`
+	Score        float64
+	Runtime      int // in milliseconds
+	Memory       int // in KB
+	RejudgedBy   uint
+
+	CreatedAt time.Time
+}
+
+// SubmissionTestResult is the outcome of one test case of a submission.
+// Stdout and Stderr are truncated.
+type SubmissionTestResult struct {
+	ID             uint   `gorm:"primaryKey"`
+	SubmissionID   uint   `gorm:"not null;index"`
+	TestCaseID     uint   `gorm:"not null;index"`
+	Verdict        string `gorm:"not null"`
+	Time           int    // CPU time in milliseconds
+	WallTime       int    // in milliseconds
+	Memory         int    // in KB
+	ExitCode       int
+	Signal         int
+	Stdout         string
+	Stderr         string
+	CheckerMessage string
+
+	CreatedAt time.Time
+}
+
+// UserProblemScore keeps the best score a user got on a problem.
+type UserProblemScore struct {
+	UserID       uint `gorm:"primaryKey"`
+	ProblemID    uint `gorm:"primaryKey"`
+	ContestID    uint `gorm:"not null;index"`
+	BestScore    float64
+	SubmissionID uint
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Language struct {
+	ID      uint   `gorm:"primaryKey"`
+	Slug    string `gorm:"uniqueIndex;not null"` // stable key sent by clients, e.g. cpp, python
+	Name    string `gorm:"not null"`
+	Version string
+	JudgeID int // language id on the judge backend, e.g. Judge0's language_id
+
+	SourceFile     string // used by the sandbox backend
+	CompileCommand string
+	RunCommand     string
+
+	// A problem's limits for this language are TimeLimit*TimeMultiplier +
+	// TimeOffset milliseconds and MemoryLimit + MemoryOffset MB, unless the
+	// problem overrides them.
+	TimeMultiplier float64 `gorm:"default:1"`
+	TimeOffset     int     // in milliseconds
+	MemoryOffset   int     // in MB
+
+	Enabled bool `gorm:"default:false;index"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Migration records a data migration that has been applied.
+type Migration struct {
+	ID        string    `gorm:"primaryKey"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// PlagiarismReport is one run of the similarity check over a contest, or a
+// single problem of it.
+type PlagiarismReport struct {
+	ID        uint   `gorm:"primaryKey"`
+	ContestID uint   `gorm:"not null;index"`
+	ProblemID *uint  `gorm:"index"`
+	Status    string `gorm:"not null"` // running, done, failed
+	Error     string
+	Threshold float64 // pairs at least this similar are reported
+	CreatedBy uint
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Pairs []PlagiarismPair `gorm:"foreignKey:ReportID;constraint:OnDelete:CASCADE;"`
+}
+
+type PlagiarismPair struct {
+	ID                 uint `gorm:"primaryKey"`
+	ReportID           uint `gorm:"not null;index"`
+	ProblemID          uint `gorm:"not null;index"`
+	FirstSubmissionID  uint `gorm:"not null"`
+	SecondSubmissionID uint `gorm:"not null"`
+	FirstUserID        uint `gorm:"not null;index"`
+	SecondUserID       uint `gorm:"not null;index"`
+	Score              float64
+
+	Status     string `gorm:"default:'pending';index"` // pending, confirmed, dismissed
+	ReviewedBy uint
+	ReviewedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Matches []PlagiarismMatch `gorm:"foreignKey:PairID;constraint:OnDelete:CASCADE;"`
+}
+
+// PlagiarismMatch is a pair of line ranges, inclusive, that match between
+// the two submissions of a pair.
+type PlagiarismMatch struct {
+	ID          uint `gorm:"primaryKey"`
+	PairID      uint `gorm:"not null;index"`
+	FirstStart  int
+	FirstEnd    int
+	SecondStart int
+	SecondEnd   int
+}
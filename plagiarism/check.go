@@ -0,0 +1,261 @@
+package plagiarism
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/queue"
+	"gorm.io/gorm"
+)
+
+const (
+	ReportRunning = "running"
+	ReportDone    = "done"
+	ReportFailed  = "failed"
+
+	PairPending   = "pending"
+	PairConfirmed = "confirmed"
+	PairDismissed = "dismissed"
+)
+
+const DefaultThreshold = 0.6
+
+// A running check touches its report every heartbeat. One left untouched for
+// staleAfter belongs to a server that stopped mid-check.
+const (
+	heartbeat  = time.Minute
+	staleAfter = 5 * time.Minute
+)
+
+const (
+	// minFingerprints skips programs too short to tell copying from
+	// coincidence.
+	minFingerprints = 10
+
+	// Fingerprints found in more than half of a problem's submissions are
+	// templates or the obvious solution, not evidence, once there are
+	// enough submissions to tell.
+	boilerplateMinDocs = 4
+)
+
+// Run checks the report's scope and stores the similar pairs it finds. It is
+// meant to run in the background; failures are recorded on the report.
+func Run(reportID uint) {
+	db := config.GetDB()
+
+	var report models.PlagiarismReport
+	if err := db.First(&report, reportID).Error; err != nil {
+		log.Println("Failed to load plagiarism report", reportID, ":", err)
+		return
+	}
+
+	stop := keepAlive(db, report.ID)
+	err := check(db, &report)
+	stop()
+
+	status, message := ReportDone, ""
+	if err != nil {
+		log.Println("Plagiarism check", reportID, "failed:", err)
+		status, message = ReportFailed, err.Error()
+	}
+
+	if err := db.Model(&report).Updates(map[string]interface{}{
+		"status": status,
+		"error":  message,
+	}).Error; err != nil {
+		log.Println("Failed to update plagiarism report", reportID, ":", err)
+	}
+}
+
+// keepAlive touches the report every heartbeat until the returned func is
+// called.
+func keepAlive(db *gorm.DB, reportID uint) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := db.Model(&models.PlagiarismReport{}).Where("id = ?", reportID).
+					Update("updated_at", time.Now()).Error; err != nil {
+					log.Println("Failed to touch plagiarism report", reportID, ":", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Start fails the reports left running by a server that stopped mid-check,
+// right away and then every heartbeat, so they do not stay running forever.
+// Every instance may run it.
+func Start() {
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			failStale(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+func failStale(now time.Time) {
+	res := config.GetDB().Model(&models.PlagiarismReport{}).
+		Where("status = ? AND updated_at < ?", ReportRunning, now.Add(-staleAfter)).
+		Updates(map[string]interface{}{
+			"status": ReportFailed,
+			"error":  "the check was interrupted, start it again",
+		})
+	if res.Error != nil {
+		log.Println("Failed to fail interrupted plagiarism reports:", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Println("Failed", res.RowsAffected, "interrupted plagiarism reports")
+	}
+}
+
+// check compares each user's latest judged contest submission per problem
+// with everyone else's in the same language.
+func check(db *gorm.DB, report *models.PlagiarismReport) error {
+	query := db.Select("id", "user_id", "problem_id", "language", "code").
+		Where("contest_id = ? AND participation_type = ? AND status NOT IN ?",
+			report.ContestID, models.ParticipationContest, []string{queue.StatusQueued, queue.StatusJudging})
+	if report.ProblemID != nil {
+		query = query.Where("problem_id = ?", *report.ProblemID)
+	}
+
+	var submissions []models.Submission
+	if err := query.Order("submitted_at DESC, id DESC").Find(&submissions).Error; err != nil {
+		return err
+	}
+
+	type groupKey struct {
+		problemID uint
+		language  string
+	}
+	type userKey struct {
+		problemID uint
+		userID    uint
+	}
+	seen := map[userKey]bool{}
+	groups := map[groupKey][]models.Submission{}
+	for _, submission := range submissions {
+		key := userKey{submission.ProblemID, submission.UserID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		group := groupKey{submission.ProblemID, submission.Language}
+		groups[group] = append(groups[group], submission)
+	}
+
+	for key, group := range groups {
+		pairs := compareGroup(group, report.Threshold)
+		for i := range pairs {
+			pairs[i].ReportID = report.ID
+			pairs[i].ProblemID = key.problemID
+		}
+		if len(pairs) == 0 {
+			continue
+		}
+		if err := db.CreateInBatches(&pairs, 100).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compareGroup(submissions []models.Submission, threshold float64) []models.PlagiarismPair {
+	sort.Slice(submissions, func(i, j int) bool { return submissions[i].ID < submissions[j].ID })
+
+	docs := make([]*document, 0, len(submissions))
+	for _, submission := range submissions {
+		docs = append(docs, newDocument(submission.ID, submission.UserID, submission.Code, submission.Language))
+	}
+
+	frequency := map[uint64]int{}
+	for _, doc := range docs {
+		for hash := range doc.hashes {
+			frequency[hash]++
+		}
+	}
+	ignored := map[uint64]bool{}
+	if len(docs) >= boilerplateMinDocs {
+		for hash, count := range frequency {
+			if count*2 > len(docs) {
+				ignored[hash] = true
+			}
+		}
+	}
+
+	index := map[uint64][]int{}
+	sizes := make([]int, len(docs))
+	for i, doc := range docs {
+		for hash := range doc.hashes {
+			if ignored[hash] {
+				continue
+			}
+			index[hash] = append(index[hash], i)
+			sizes[i]++
+		}
+	}
+
+	shared := map[[2]int]int{}
+	for _, holders := range index {
+		for x := 0; x < len(holders); x++ {
+			for y := x + 1; y < len(holders); y++ {
+				if docs[holders[x]].userID != docs[holders[y]].userID {
+					shared[[2]int{holders[x], holders[y]}]++
+				}
+			}
+		}
+	}
+
+	var pairs []models.PlagiarismPair
+	for key, count := range shared {
+		a, b := docs[key[0]], docs[key[1]]
+		smaller := min(sizes[key[0]], sizes[key[1]])
+		if smaller < minFingerprints {
+			continue
+		}
+		score := float64(count) / float64(smaller)
+		if score < threshold {
+			continue
+		}
+
+		pair := models.PlagiarismPair{
+			FirstSubmissionID:  a.submissionID,
+			SecondSubmissionID: b.submissionID,
+			FirstUserID:        a.userID,
+			SecondUserID:       b.userID,
+			Score:              score,
+			Status:             PairPending,
+		}
+		for _, region := range regions(a, b, ignored) {
+			pair.Matches = append(pair.Matches, models.PlagiarismMatch{
+				FirstStart:  region.FirstStart,
+				FirstEnd:    region.FirstEnd,
+				SecondStart: region.SecondStart,
+				SecondEnd:   region.SecondEnd,
+			})
+		}
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		return pairs[i].FirstSubmissionID < pairs[j].FirstSubmissionID
+	})
+	return pairs
+}
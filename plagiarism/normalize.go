@@ -0,0 +1,149 @@
+package plagiarism
+
+import (
+	"strings"
+	"unicode"
+)
+
+// token is one lexical unit of normalized source. Identifiers, literals and
+// layout are erased so renaming variables or reformatting does not hide a
+// copy; keywords and punctuation keep the program's structure.
+type token struct {
+	text string
+	line int
+}
+
+type syntax struct {
+	lineComment  string
+	blockComment bool // /* ... */
+	preprocessor bool // drop whole # lines, mostly includes and macros
+	tripleQuotes bool
+}
+
+var (
+	cSyntax      = syntax{lineComment: "//", blockComment: true, preprocessor: true}
+	cLikeSyntax  = syntax{lineComment: "//", blockComment: true}
+	pythonSyntax = syntax{lineComment: "#", tripleQuotes: true}
+)
+
+// syntaxFor picks the comment and string rules for a catalog slug. Unknown
+// languages are treated like Java, which is close enough for most.
+func syntaxFor(language string) syntax {
+	language = strings.ToLower(language)
+	switch {
+	case strings.HasPrefix(language, "py"):
+		return pythonSyntax
+	case language == "c" || strings.HasPrefix(language, "cpp") || strings.HasPrefix(language, "c++"):
+		return cSyntax
+	}
+	return cLikeSyntax
+}
+
+var keywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		auto break case catch class const continue default delete do else enum
+		extern for goto if inline namespace new operator private protected public
+		register return sizeof static struct switch template this throw try
+		typedef typename union using virtual void volatile while
+		int long short char bool float double signed unsigned
+		abstract extends final finally implements import instanceof interface
+		package super synchronized throws
+		chan defer fallthrough func go map range select type var
+		and as assert async await def del elif except from global in is lambda
+		nonlocal not or pass raise with yield
+		function let of
+		true false null nil None True False`) {
+		keywords[word] = true
+	}
+}
+
+func normalize(source, language string) []token {
+	syn := syntaxFor(language)
+	src := []rune(strings.ReplaceAll(source, "\r\n", "\n"))
+
+	var tokens []token
+	line := 1
+	atLineStart := true
+
+	for i := 0; i < len(src); {
+		r := src[i]
+		rest := string(src[i:min(i+3, len(src))])
+
+		switch {
+		case r == '\n':
+			line++
+			atLineStart = true
+			i++
+			continue
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case syn.preprocessor && atLineStart && r == '#',
+			strings.HasPrefix(rest, syn.lineComment):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case syn.blockComment && strings.HasPrefix(rest, "/*"):
+			i += 2
+			for i < len(src) && !(src[i] == '*' && i+1 < len(src) && src[i+1] == '/') {
+				if src[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i += 2
+			continue
+		}
+
+		atLineStart = false
+		start := line
+
+		switch {
+		case syn.tripleQuotes && (rest == `"""` || rest == `'''`):
+			i += 3
+			for i < len(src) && !(i+3 <= len(src) && string(src[i:i+3]) == rest) {
+				if src[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i += 3
+			tokens = append(tokens, token{"str", start})
+		case r == '"' || r == '\'' || r == '`':
+			i++
+			for i < len(src) && src[i] != r && (r == '`' || src[i] != '\n') {
+				if src[i] == '\\' {
+					i++
+				} else if src[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i++
+			tokens = append(tokens, token{"str", start})
+		case unicode.IsDigit(r):
+			for i < len(src) && (unicode.IsLetter(src[i]) || unicode.IsDigit(src[i]) || src[i] == '.' || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{"num", start})
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i
+			for j < len(src) && (unicode.IsLetter(src[j]) || unicode.IsDigit(src[j]) || src[j] == '_' || src[j] == '$') {
+				j++
+			}
+			word := string(src[i:j])
+			i = j
+			if !keywords[word] {
+				word = "id"
+			}
+			tokens = append(tokens, token{word, start})
+		default:
+			tokens = append(tokens, token{string(r), start})
+			i++
+		}
+	}
+	return tokens
+}
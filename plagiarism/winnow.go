@@ -0,0 +1,124 @@
+package plagiarism
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// Fingerprints of k tokens, one picked from every window of w consecutive
+// k-grams, guarantee that any common run of at least k+w-1 tokens is found.
+const (
+	kgram  = 8
+	window = 4
+)
+
+type fingerprint struct {
+	hash uint64
+	pos  int // index of the k-gram's first token
+}
+
+type document struct {
+	submissionID uint
+	userID       uint
+	tokens       []token
+	prints       []fingerprint
+	hashes       map[uint64]int // first position of each fingerprint
+}
+
+func newDocument(submissionID, userID uint, source, language string) *document {
+	doc := &document{
+		submissionID: submissionID,
+		userID:       userID,
+		tokens:       normalize(source, language),
+	}
+	doc.prints = winnow(doc.tokens)
+	doc.hashes = make(map[uint64]int, len(doc.prints))
+	for _, print := range doc.prints {
+		if _, ok := doc.hashes[print.hash]; !ok {
+			doc.hashes[print.hash] = print.pos
+		}
+	}
+	return doc
+}
+
+func winnow(tokens []token) []fingerprint {
+	if len(tokens) < kgram {
+		return nil
+	}
+
+	grams := make([]uint64, len(tokens)-kgram+1)
+	for i := range grams {
+		h := fnv.New64a()
+		for _, tok := range tokens[i : i+kgram] {
+			h.Write([]byte(tok.text))
+			h.Write([]byte{0})
+		}
+		grams[i] = h.Sum64()
+	}
+
+	var prints []fingerprint
+	last := -1
+	windows := max(len(grams)-window+1, 1)
+	for start := 0; start < windows; start++ {
+		end := min(start+window, len(grams))
+		// The rightmost minimum, so a window sliding over a run of equal
+		// hashes keeps the same pick.
+		best := start
+		for i := start; i < end; i++ {
+			if grams[i] <= grams[best] {
+				best = i
+			}
+		}
+		if best != last {
+			prints = append(prints, fingerprint{grams[best], best})
+			last = best
+		}
+	}
+	return prints
+}
+
+// region is a pair of line ranges that match between two submissions.
+type region struct {
+	FirstStart, FirstEnd   int
+	SecondStart, SecondEnd int
+}
+
+// regions maps the shared fingerprints back to source lines, merging
+// matches that continue each other in both documents.
+func regions(a, b *document, ignored map[uint64]bool) []region {
+	type match struct{ a, b int }
+	var matches []match
+	for hash, posA := range a.hashes {
+		if ignored[hash] {
+			continue
+		}
+		if posB, ok := b.hashes[hash]; ok {
+			matches = append(matches, match{posA, posB})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].a < matches[j].a })
+
+	var spans [][4]int // token ranges: a start, a end, b start, b end
+	for _, m := range matches {
+		if n := len(spans); n > 0 {
+			last := &spans[n-1]
+			if m.a <= last[1]+window && m.b >= last[2] && m.b <= last[3]+window {
+				last[1] = max(last[1], m.a+kgram-1)
+				last[3] = max(last[3], m.b+kgram-1)
+				continue
+			}
+		}
+		spans = append(spans, [4]int{m.a, m.a + kgram - 1, m.b, m.b + kgram - 1})
+	}
+
+	result := make([]region, 0, len(spans))
+	for _, span := range spans {
+		result = append(result, region{
+			FirstStart:  a.tokens[span[0]].line,
+			FirstEnd:    a.tokens[span[1]].line,
+			SecondStart: b.tokens[span[2]].line,
+			SecondEnd:   b.tokens[span[3]].line,
+		})
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package plagiarism
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ankush-web-eng/contest-backend/models"
+)
+
+const original = `#include <bits/stdc++.h>
+using namespace std;
+
+int main() {
+    int n;
+    cin >> n;
+    vector<long long> a(n);
+    for (int i = 0; i < n; i++) cin >> a[i];
+    long long best = a[0], cur = 0;
+    for (int i = 0; i < n; i++) {
+        cur = max(a[i], cur + a[i]);
+        best = max(best, cur);
+    }
+    cout << best << "\n";
+    return 0;
+}
+`
+
+// renamed is original with other names, comments and layout.
+const renamed = `#include <iostream>
+#include <vector>
+using namespace std;
+// kadane
+int main()
+{
+    int count; cin >> count;
+    vector<long long> values(count);
+    for (int j = 0; j < count; j++)
+        cin >> values[j];
+    /* running maximum */
+    long long answer = values[0], run = 0;
+    for (int j = 0; j < count; j++)
+    {
+        run = max(values[j], run + values[j]);
+        answer = max(answer, run);
+    }
+    cout << answer << '\n';
+    return 0;
+}
+`
+
+const unrelated = `#include <bits/stdc++.h>
+using namespace std;
+
+int main() {
+    string s;
+    cin >> s;
+    map<char, int> seen;
+    while (!s.empty()) {
+        seen[s.back()]++;
+        s.pop_back();
+    }
+    if (seen.size() % 2 == 1) {
+        puts("odd");
+    } else {
+        puts("even");
+    }
+}
+`
+
+func texts(tokens []token) string {
+	parts := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		parts = append(parts, tok.text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		source   string
+		want     string
+	}{
+		{"identifiers and literals", "cpp", `x = y + 42; s = "a b";`, `id = id + num ; id = str ;`},
+		{"keywords kept", "cpp", `for (int i = 0;;) return i;`, `for ( int id = num ; ; ) return id ;`},
+		{"comments dropped", "cpp", "a; // b c\n/* d\ne */ f;", `id ; id ;`},
+		{"preprocessor dropped", "cpp", "#include <x>\n#define N 5\nN;", `id ;`},
+		{"hash is not a comment in java", "java", "a // #b\n#c", `id #  id`},
+		{"python comments and docstrings", "python", "def f(x):\n    '''doc\n    string'''\n    return x # done", `def id ( id ) : str return id`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := texts(normalize(tt.source, tt.language))
+			if got != strings.Join(strings.Fields(tt.want), " ") {
+				t.Errorf("normalize = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLines(t *testing.T) {
+	tokens := normalize("a\n/* x\ny */ b\n`c\nd` e", "javascript")
+	want := []int{1, 3, 4, 5}
+	if len(tokens) != len(want) {
+		t.Fatalf("tokens = %+v, want %d of them", tokens, len(want))
+	}
+	for i, tok := range tokens {
+		if tok.line != want[i] {
+			t.Errorf("token %d %q on line %d, want %d", i, tok.text, tok.line, want[i])
+		}
+	}
+}
+
+func TestWinnow(t *testing.T) {
+	seq := func(words string) []token {
+		var tokens []token
+		for _, word := range strings.Fields(words) {
+			tokens = append(tokens, token{text: word})
+		}
+		return tokens
+	}
+	shared := "a b c d e f g h i j k" // kgram+window-1 tokens
+
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		common bool
+	}{
+		{"too short", "a b c", "a b c", false},
+		{"identical", shared, shared, true},
+		{"shared run in the middle", "x y z " + shared + " q", "p " + shared + " r s t", true},
+		{"nothing shared", "a b c d e f g h i j k", "l m n o p q r s t u v", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := winnow(seq(tt.first)), winnow(seq(tt.second))
+			hashes := map[uint64]bool{}
+			for _, print := range first {
+				hashes[print.hash] = true
+			}
+			common := false
+			for _, print := range second {
+				common = common || hashes[print.hash]
+			}
+			if common != tt.common {
+				t.Errorf("shared fingerprint = %v, want %v", common, tt.common)
+			}
+		})
+	}
+}
+
+func TestWinnowPositions(t *testing.T) {
+	prints := winnow(normalize(original, "cpp"))
+	if len(prints) == 0 {
+		t.Fatal("no fingerprints")
+	}
+	for i := 1; i < len(prints); i++ {
+		if prints[i].pos <= prints[i-1].pos {
+			t.Fatalf("positions not increasing: %d after %d", prints[i].pos, prints[i-1].pos)
+		}
+		// Every window of w k-grams has a fingerprint in it.
+		if prints[i].pos-prints[i-1].pos > window {
+			t.Fatalf("gap of %d k-grams between fingerprints", prints[i].pos-prints[i-1].pos)
+		}
+	}
+}
+
+func TestCompareGroup(t *testing.T) {
+	submission := func(id, userID uint, code string) models.Submission {
+		return models.Submission{ID: id, UserID: userID, Language: "cpp", Code: code}
+	}
+
+	tests := []struct {
+		name        string
+		submissions []models.Submission
+		want        [][2]uint // submission ids of the reported pairs
+	}{
+		{
+			name:        "renamed copy",
+			submissions: []models.Submission{submission(1, 10, original), submission(2, 20, renamed)},
+			want:        [][2]uint{{1, 2}},
+		},
+		{
+			name:        "different programs",
+			submissions: []models.Submission{submission(1, 10, original), submission(2, 20, unrelated)},
+		},
+		{
+			name:        "same user",
+			submissions: []models.Submission{submission(1, 10, original), submission(2, 10, renamed)},
+		},
+		{
+			name:        "too short to tell",
+			submissions: []models.Submission{submission(1, 10, "int main() {}"), submission(2, 20, "int main() {}")},
+		},
+		{
+			name: "only the copy among others",
+			submissions: []models.Submission{
+				submission(4, 40, unrelated),
+				submission(1, 10, original),
+				submission(3, 30, "int main() { puts(\"hello\"); return 0; }"),
+				submission(2, 20, renamed),
+			},
+			want: [][2]uint{{1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs := compareGroup(tt.submissions, DefaultThreshold)
+			if len(pairs) != len(tt.want) {
+				t.Fatalf("got %d pairs %+v, want %v", len(pairs), pairs, tt.want)
+			}
+			for i, pair := range pairs {
+				if got := [2]uint{pair.FirstSubmissionID, pair.SecondSubmissionID}; got != tt.want[i] {
+					t.Errorf("pair %d = %v, want %v", i, got, tt.want[i])
+				}
+				if pair.Score < DefaultThreshold || pair.Score > 1 {
+					t.Errorf("pair %d score = %v", i, pair.Score)
+				}
+				if len(pair.Matches) == 0 {
+					t.Errorf("pair %d has no matching regions", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/languages"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"github.com/ankush-web-eng/contest-backend/stats"
+	"gorm.io/gorm"
+)
+
+var errSuperseded = errors.New("submission is no longer being judged")
+
+func judgeSubmission(ctx context.Context, id uint) error {
+	db := config.GetDB()
+
+	var submission models.Submission
+	if err := db.First(&submission, id).Error; err != nil {
+		return err
+	}
+
+	language, err := languages.Find(submission.Language)
+	if err != nil {
+		return err
+	}
+
+	var problem models.Problem
+	if err := db.Preload("Contest").Preload("TestCases").Preload("TestGroups.Dependencies").First(&problem, submission.ProblemID).Error; err != nil {
+		return err
+	}
+	if len(problem.TestCases) == 0 {
+		return fmt.Errorf("problem %d has no test cases", problem.ID)
+	}
+
+	tests, groups := problemTests(&problem)
+
+	checker, err := problemChecker(&problem)
+	if err != nil {
+		return err
+	}
+	interactor, err := problemInteractor(&problem)
+	if err != nil {
+		return err
+	}
+	limits, err := languages.ProblemLimits(&problem, language)
+	if err != nil {
+		return err
+	}
+
+	grade, err := judge.Grade(ctx, judge.GetJudge(), judge.GradeRequest{
+		SourceCode:  submission.Code,
+		Language:    languages.ToJudge(language),
+		TimeLimit:   limits.TimeLimit,
+		MemoryLimit: limits.MemoryLimit * 1024,
+		Tests:       tests,
+		Checker:     checker,
+		Interactor:  interactor,
+		Groups:      groups,
+		FullScore:   float64(problem.Score),
+		Parallelism: cfg.TestWorkers,
+		StopPolicy:  stopPolicy(&problem),
+	})
+	if err != nil {
+		return err
+	}
+
+	results, runtime, memory := testResults(submission.ID, grade)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// A rejudge while this worker was busy puts the submission back to
+		// queued; its result may be based on outdated tests, so drop it.
+		res := tx.Model(&submission).
+			Where("status = ?", StatusJudging).
+			Updates(map[string]interface{}{
+				"status":         grade.Verdict,
+				"score":          grade.Score,
+				"runtime":        runtime,
+				"memory":         memory,
+				"compile_output": truncateOutput(grade.CompileOutput),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errSuperseded
+		}
+
+		if err := tx.Where("submission_id = ?", submission.ID).Delete(&models.SubmissionTestResult{}).Error; err != nil {
+			return err
+		}
+		if len(results) > 0 {
+			if err := tx.Create(&results).Error; err != nil {
+				return err
+			}
+		}
+		if err := stats.Record(tx, &submission, grade.Verdict); err != nil {
+			return err
+		}
+		if submission.VirtualParticipationID != nil {
+			if err := refreshVirtualScore(tx, *submission.VirtualParticipationID); err != nil {
+				return err
+			}
+		}
+		if err := refreshScores(tx, submission.UserID, &problem); err != nil {
+			return err
+		}
+		if submission.ParticipationType == models.ParticipationPractice {
+			return nil
+		}
+		return standings.MarkStale(tx, problem.ContestID)
+	})
+	if err == errSuperseded {
+		log.Println("Submission", id, "was rejudged while judging, discarding its result")
+		return nil
+	}
+	return err
+}
+
+// maxOutput bounds how much of each stream is kept per test.
+const maxOutput = 4096
+
+func truncateOutput(s string) string {
+	return judge.TruncateOutput(s, maxOutput)
+}
+
+// testResults converts graded tests into rows, along with the slowest run
+// time and the peak memory across them.
+func testResults(submissionID uint, grade *judge.GradeResult) ([]models.SubmissionTestResult, int, int) {
+	var runtime, memory int
+	results := make([]models.SubmissionTestResult, 0, len(grade.Results))
+	for _, test := range grade.Results {
+		result := models.SubmissionTestResult{
+			SubmissionID:   submissionID,
+			TestCaseID:     test.TestCaseID,
+			Verdict:        test.Verdict,
+			CheckerMessage: truncateOutput(test.Message),
+		}
+		if run := test.Execution; run != nil {
+			result.Time = run.Time
+			result.WallTime = run.WallTime
+			result.Memory = run.Memory
+			result.ExitCode = run.ExitCode
+			result.Signal = run.Signal
+			result.Stdout = truncateOutput(run.Stdout)
+			result.Stderr = truncateOutput(run.Stderr)
+
+			runtime = max(runtime, run.Time)
+			memory = max(memory, run.Memory)
+		}
+		results = append(results, result)
+	}
+	return results, runtime, memory
+}
+
+func stopPolicy(problem *models.Problem) string {
+	if problem.StopPolicy != "" {
+		return problem.StopPolicy
+	}
+	return problem.Contest.StopPolicy
+}
+
+func problemTests(problem *models.Problem) ([]judge.TestSpec, []judge.GroupSpec) {
+	tests := make([]judge.TestSpec, 0, len(problem.TestCases))
+	for _, testCase := range problem.TestCases {
+		test := judge.TestSpec{
+			ID:     testCase.ID,
+			Input:  testCase.Input,
+			Output: testCase.Output,
+		}
+		if testCase.GroupID != nil {
+			test.GroupID = *testCase.GroupID
+		}
+		tests = append(tests, test)
+	}
+
+	groups := make([]judge.GroupSpec, 0, len(problem.TestGroups))
+	for _, group := range problem.TestGroups {
+		spec := judge.GroupSpec{
+			ID:     group.ID,
+			Points: group.Points,
+			Policy: group.ScoringPolicy,
+		}
+		for _, dep := range group.Dependencies {
+			spec.Dependencies = append(spec.Dependencies, dep.ID)
+		}
+		groups = append(groups, spec)
+	}
+	return tests, groups
+}
+
+func problemChecker(problem *models.Problem) (judge.Checker, error) {
+	spec := judge.CheckerSpec{
+		Mode:   problem.CheckerMode,
+		AbsEps: problem.CheckerAbsEps,
+		RelEps: problem.CheckerRelEps,
+		Source: problem.CheckerSource,
+	}
+	if problem.CheckerMode == judge.CheckerCustom {
+		language, err := languages.Find(problem.CheckerLanguage)
+		if err != nil {
+			return nil, err
+		}
+		spec.Language = languages.ToJudge(language)
+	}
+	return judge.NewChecker(spec)
+}
+
+func problemInteractor(problem *models.Problem) (*judge.InteractorSpec, error) {
+	if problem.InteractorSource == "" {
+		return nil, nil
+	}
+	language, err := languages.Find(problem.InteractorLanguage)
+	if err != nil {
+		return nil, err
+	}
+	return &judge.InteractorSpec{
+		Source:   problem.InteractorSource,
+		Language: languages.ToJudge(language),
+	}, nil
+}
@@ -0,0 +1,167 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/models"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusJudging = "judging"
+)
+
+var (
+	jobs chan uint
+	cfg  config.QueueConfig
+
+	// enqueued holds the ids sitting in jobs, so the poller does not hand
+	// them out a second time.
+	enqueuedMu sync.Mutex
+	enqueued   = map[uint]bool{}
+)
+
+// Start launches the worker pool. Submissions are persisted as queued before
+// they reach the channel, so the channel is only a fast path: the poller
+// picks up anything it dropped, anything left by another instance, and
+// anything a crashed worker was judging, and restarts problem validations a
+// stopped server left running.
+func Start(queueConfig config.QueueConfig) {
+	cfg = queueConfig
+	jobs = make(chan uint, cfg.Capacity)
+
+	for i := 0; i < cfg.Workers; i++ {
+		go work()
+	}
+	go poll()
+}
+
+// Enqueue hands a queued submission to the local workers without blocking.
+// A submission already waiting in the channel is not added again.
+func Enqueue(submissionID uint) {
+	enqueuedMu.Lock()
+	defer enqueuedMu.Unlock()
+	if enqueued[submissionID] {
+		return
+	}
+
+	select {
+	case jobs <- submissionID:
+		enqueued[submissionID] = true
+	default:
+		log.Println("Judge queue is full, submission", submissionID, "will be picked up by the poller")
+	}
+}
+
+// waiting lists the ids sitting in the channel.
+func waiting() []uint {
+	enqueuedMu.Lock()
+	defer enqueuedMu.Unlock()
+	ids := make([]uint, 0, len(enqueued))
+	for id := range enqueued {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func work() {
+	for id := range jobs {
+		enqueuedMu.Lock()
+		delete(enqueued, id)
+		enqueuedMu.Unlock()
+
+		if !claim(id) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.JudgeTimeout)
+		err := judgeSubmission(ctx, id)
+		cancel()
+
+		switch {
+		case errors.Is(err, judge.ErrJudgeUnavailable):
+			// The poller hands it out again once the next tick comes round.
+			log.Println("Judge is unavailable, requeueing submission", id, ":", err)
+			requeue(id)
+		case err != nil:
+			log.Println("Failed to judge submission", id, ":", err)
+			markError(id)
+		}
+	}
+}
+
+// claim moves a submission from queued to judging. Only one worker across
+// all instances can win the update.
+func claim(id uint) bool {
+	db := config.GetDB()
+	res := db.Model(&models.Submission{}).
+		Where("id = ? AND status = ?", id, StatusQueued).
+		Update("status", StatusJudging)
+	if res.Error != nil {
+		log.Println("Failed to claim submission", id, ":", res.Error)
+		return false
+	}
+	return res.RowsAffected == 1
+}
+
+func requeue(id uint) {
+	db := config.GetDB()
+	if err := db.Model(&models.Submission{}).
+		Where("id = ? AND status = ?", id, StatusJudging).
+		Update("status", StatusQueued).Error; err != nil {
+		log.Println("Failed to requeue submission", id, ":", err)
+	}
+}
+
+func markError(id uint) {
+	db := config.GetDB()
+	if err := db.Model(&models.Submission{}).
+		Where("id = ? AND status = ?", id, StatusJudging).
+		Update("status", judge.VerdictInternalError).Error; err != nil {
+		log.Println("Failed to mark submission", id, "as errored:", err)
+	}
+}
+
+func poll() {
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		db := config.GetDB()
+
+		if err := db.Model(&models.Submission{}).
+			Where("status = ? AND updated_at < ?", StatusJudging, time.Now().Add(-cfg.StaleAfter)).
+			Update("status", StatusQueued).Error; err != nil {
+			log.Println("Failed to requeue stale submissions:", err)
+		}
+		resumeValidations(time.Now())
+
+		free := cap(jobs) - len(jobs)
+		if free == 0 {
+			continue
+		}
+
+		query := db.Model(&models.Submission{}).Where("status = ?", StatusQueued)
+		if ids := waiting(); len(ids) > 0 {
+			query = query.Where("id NOT IN ?", ids)
+		}
+
+		var ids []uint
+		if err := query.
+			Order("id").
+			Limit(free).
+			Pluck("id", &ids).Error; err != nil {
+			log.Println("Failed to poll queued submissions:", err)
+			continue
+		}
+		for _, id := range ids {
+			Enqueue(id)
+		}
+	}
+}
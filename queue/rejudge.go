@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/stats"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rejudgeBatch keeps the id lists well under Postgres' parameter limit.
+const rejudgeBatch = 1000
+
+// Rejudge moves every submission matched by scope back to queued, first
+// recording the verdict each one had. Submissions still waiting in the
+// queue are left alone since they will see the current tests anyway. A
+// worker judging one of them loses its result, see judgeSubmission. The
+// old verdicts leave the statistics here and the new ones are counted when
+// they come in; scores are recomputed as each submission finishes.
+func Rejudge(scope func(*gorm.DB) *gorm.DB, adminID uint) (int, error) {
+	db := config.GetDB()
+
+	var ids []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Submission{}).
+			Scopes(scope).
+			Where("status <> ?", StatusQueued).
+			Order("id").
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		for start := 0; start < len(ids); start += rejudgeBatch {
+			batch := ids[start:min(start+rejudgeBatch, len(ids))]
+
+			var submissions []models.Submission
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id", "status", "score", "runtime", "memory").
+				Where("id IN ?", batch).
+				Find(&submissions).Error; err != nil {
+				return err
+			}
+
+			history := make([]models.SubmissionVerdictHistory, 0, len(submissions))
+			for _, submission := range submissions {
+				if submission.Status == StatusJudging {
+					continue
+				}
+				history = append(history, models.SubmissionVerdictHistory{
+					SubmissionID: submission.ID,
+					Status:       submission.Status,
+					Score:        submission.Score,
+					Runtime:      submission.Runtime,
+					Memory:       submission.Memory,
+					RejudgedBy:   adminID,
+				})
+			}
+			if len(history) > 0 {
+				if err := tx.Create(&history).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Model(&models.Submission{}).
+				Where("id IN ?", batch).
+				Update("status", StatusQueued).Error; err != nil {
+				return err
+			}
+		}
+
+		// Every submission is locked by now, so a worker finishing one of
+		// them cannot hold a user row this waits on.
+		for start := 0; start < len(ids); start += rejudgeBatch {
+			if err := stats.Retract(tx, ids[start:min(start+rejudgeBatch, len(ids))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		Enqueue(id)
+	}
+	return len(ids), nil
+}
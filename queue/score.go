@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refreshScores recomputes the user's best score on the problem from their
+// judged contest submissions. Practice submissions never change it.
+// Recomputing rather than taking a max keeps it right after a rejudge lowers
+// a score.
+func refreshScores(tx *gorm.DB, userID uint, problem *models.Problem) error {
+	var best models.Submission
+	err := tx.Where("user_id = ? AND problem_id = ? AND participation_type = ? AND status NOT IN ?",
+		userID, problem.ID, models.ParticipationContest, []string{StatusQueued, StatusJudging}).
+		Order("score DESC, submitted_at ASC").
+		First(&best).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	score := models.UserProblemScore{
+		UserID:       userID,
+		ProblemID:    problem.ID,
+		ContestID:    problem.ContestID,
+		BestScore:    best.Score,
+		SubmissionID: best.ID,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "problem_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"best_score", "submission_id", "updated_at"}),
+	}).Create(&score).Error
+}
+
+// refreshVirtualScore recomputes a virtual run's score as the sum of its best
+// judged score on each problem.
+func refreshVirtualScore(tx *gorm.DB, virtualID uint) error {
+	best := tx.Model(&models.Submission{}).
+		Select("MAX(score) AS score").
+		Where("virtual_participation_id = ? AND status NOT IN ?", virtualID, []string{StatusQueued, StatusJudging}).
+		Group("problem_id")
+
+	var total float64
+	if err := tx.Table("(?) AS best", best).
+		Select("COALESCE(SUM(score), 0)").
+		Scan(&total).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.VirtualParticipation{}).
+		Where("id = ?", virtualID).
+		Update("score", total).Error
+}
@@ -0,0 +1,240 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/languages"
+	"github.com/ankush-web-eng/contest-backend/models"
+)
+
+const (
+	ValidationRunning = "running"
+	ValidationPassed  = "passed"
+	ValidationFailed  = "failed"
+)
+
+// A running validation is touched every validationHeartbeat; one untouched
+// for validationStaleAfter was left by a server that stopped, and the poller
+// starts it again.
+const (
+	validationHeartbeat  = time.Minute
+	validationStaleAfter = 5 * time.Minute
+)
+
+// ValidExpectedVerdict reports whether a reference solution can be expected
+// to get the verdict.
+func ValidExpectedVerdict(verdict string) bool {
+	return verdict == judge.VerdictAccepted || verdict == judge.VerdictWrongAnswer || verdict == judge.VerdictTimeLimit
+}
+
+// RunValidation grades every reference solution of the validation's problem
+// on every test and stores the outcome. It is meant to run in the
+// background.
+func RunValidation(validationID uint) {
+	db := config.GetDB()
+
+	var validation models.ProblemValidation
+	if err := db.First(&validation, validationID).Error; err != nil {
+		log.Println("Failed to load problem validation", validationID, ":", err)
+		return
+	}
+
+	stop := touchValidation(validation.ID)
+	results, err := validate(context.Background(), validation.ProblemID)
+	stop()
+
+	status, message := ValidationPassed, ""
+	switch {
+	case err != nil:
+		log.Println("Problem validation", validationID, "failed:", err)
+		status, message = ValidationFailed, err.Error()
+	default:
+		for _, result := range results {
+			if !result.Passed {
+				status, message = ValidationFailed, "a reference solution did not get its expected verdict"
+				break
+			}
+		}
+	}
+
+	for i := range results {
+		results[i].ValidationID = validation.ID
+	}
+	if len(results) > 0 {
+		if err := db.Create(&results).Error; err != nil {
+			log.Println("Failed to store results of problem validation", validationID, ":", err)
+			status, message = ValidationFailed, "could not store the results"
+		}
+	}
+	if err := db.Model(&validation).Updates(map[string]interface{}{
+		"status":  status,
+		"message": message,
+	}).Error; err != nil {
+		log.Println("Failed to update problem validation", validationID, ":", err)
+	}
+}
+
+// touchValidation marks the validation alive every heartbeat until the
+// returned func is called.
+func touchValidation(validationID uint) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(validationHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := config.GetDB().Model(&models.ProblemValidation{}).Where("id = ?", validationID).
+					Update("updated_at", time.Now()).Error; err != nil {
+					log.Println("Failed to touch problem validation", validationID, ":", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// resumeValidations restarts the validations nobody has touched for
+// validationStaleAfter. Claiming one touches it, so a single instance
+// restarts each.
+func resumeValidations(now time.Time) {
+	db := config.GetDB()
+	cutoff := now.Add(-validationStaleAfter)
+
+	var ids []uint
+	if err := db.Model(&models.ProblemValidation{}).
+		Where("status = ? AND updated_at < ?", ValidationRunning, cutoff).
+		Pluck("id", &ids).Error; err != nil {
+		log.Println("Failed to find interrupted problem validations:", err)
+		return
+	}
+
+	for _, id := range ids {
+		res := db.Model(&models.ProblemValidation{}).
+			Where("id = ? AND status = ? AND updated_at < ?", id, ValidationRunning, cutoff).
+			Update("updated_at", now)
+		if res.Error != nil {
+			log.Println("Failed to claim problem validation", id, ":", res.Error)
+			continue
+		}
+		if res.RowsAffected == 1 {
+			log.Println("Restarting interrupted problem validation", id)
+			go RunValidation(id)
+		}
+	}
+}
+
+func validate(ctx context.Context, problemID uint) ([]models.ReferenceSolutionResult, error) {
+	db := config.GetDB()
+
+	var problem models.Problem
+	if err := db.Preload("ReferenceSolutions").Preload("TestCases").Preload("TestGroups.Dependencies").
+		First(&problem, problemID).Error; err != nil {
+		return nil, err
+	}
+	if len(problem.TestCases) == 0 {
+		return nil, errors.New("the problem has no test cases")
+	}
+
+	hasAccepted := false
+	for _, solution := range problem.ReferenceSolutions {
+		if solution.ExpectedVerdict == judge.VerdictAccepted {
+			hasAccepted = true
+		}
+	}
+	if !hasAccepted {
+		return nil, errors.New("the problem needs a reference solution that must be accepted")
+	}
+
+	tests, groups := problemTests(&problem)
+	checker, err := problemChecker(&problem)
+	if err != nil {
+		return nil, err
+	}
+	interactor, err := problemInteractor(&problem)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ReferenceSolutionResult, 0, len(problem.ReferenceSolutions))
+	for _, solution := range problem.ReferenceSolutions {
+		language, err := languages.Find(solution.Language)
+		if err != nil {
+			return nil, fmt.Errorf("reference solution %q: %w", solution.Name, err)
+		}
+		limits, err := languages.ProblemLimits(&problem, language)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every solution gets the judging budget a submission would.
+		solutionCtx, cancel := context.WithTimeout(ctx, cfg.JudgeTimeout)
+		grade, err := judge.Grade(solutionCtx, judge.GetJudge(), judge.GradeRequest{
+			SourceCode:  solution.Source,
+			Language:    languages.ToJudge(language),
+			TimeLimit:   limits.TimeLimit,
+			MemoryLimit: limits.MemoryLimit * 1024,
+			Tests:       tests,
+			Checker:     checker,
+			Interactor:  interactor,
+			Groups:      groups,
+			FullScore:   float64(problem.Score),
+			Parallelism: cfg.TestWorkers,
+			StopPolicy:  judge.StopNever,
+		})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("reference solution %q: %w", solution.Name, err)
+		}
+		results = append(results, checkExpectation(solution, grade))
+	}
+	return results, nil
+}
+
+// checkExpectation decides whether a graded reference solution behaved as
+// its setter said. An accepted solution must pass every test; a WA or TLE
+// solution must get that verdict on some test and never fail to compile or
+// break the judge.
+func checkExpectation(solution models.ReferenceSolution, grade *judge.GradeResult) models.ReferenceSolutionResult {
+	result := models.ReferenceSolutionResult{
+		ReferenceSolutionID: solution.ID,
+		ExpectedVerdict:     solution.ExpectedVerdict,
+		Verdict:             grade.Verdict,
+	}
+
+	var expectedOn *uint
+	for _, test := range grade.Results {
+		if test.Execution != nil {
+			result.MaxTime = max(result.MaxTime, test.Execution.Time)
+		}
+		id := test.TestCaseID
+
+		switch {
+		case test.Verdict == judge.VerdictCompileError || test.Verdict == judge.VerdictInternalError:
+			result.FailedTestCaseID = &id
+			result.Message = test.Verdict + ": " + test.Message
+			return result
+		case solution.ExpectedVerdict == judge.VerdictAccepted && test.Verdict != judge.VerdictAccepted:
+			result.FailedTestCaseID = &id
+			result.Message = fmt.Sprintf("expected AC, got %s on test %d", test.Verdict, id)
+			return result
+		case test.Verdict == solution.ExpectedVerdict && expectedOn == nil:
+			expectedOn = &id
+		}
+	}
+
+	if solution.ExpectedVerdict != judge.VerdictAccepted && expectedOn == nil {
+		result.Message = fmt.Sprintf("expected %s on some test, but it never happened", solution.ExpectedVerdict)
+		return result
+	}
+	result.Passed = true
+	return result
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+)
+
+// runLockClass namespaces the advisory locks taken per user for runs, apart
+// from submissions and the standings.
+const runLockClass = 3
+
+// CheckRun enforces the custom run limit for a user who is about to run code
+// now, and forgets the user's runs that have left the window. Like
+// CheckSubmission it holds a per-user advisory lock until tx ends, so the
+// limit holds across every server instance; the caller records the run in
+// the same transaction. tx must be a transaction.
+func CheckRun(tx *gorm.DB, cfg config.RunConfig, userID uint, now time.Time) (*Violation, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", runLockClass, int32(userID)).Error; err != nil {
+		return nil, err
+	}
+
+	since := now.Add(-cfg.RateWindow)
+	if err := tx.Where("user_id = ? AND run_at <= ?", userID, since).Delete(&models.CodeRun{}).Error; err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit <= 0 {
+		return nil, nil
+	}
+
+	var recent []time.Time
+	if err := tx.Model(&models.CodeRun{}).
+		Where("user_id = ? AND run_at > ?", userID, since).
+		Order("run_at DESC").
+		Limit(cfg.RateLimit).
+		Pluck("run_at", &recent).Error; err != nil {
+		return nil, err
+	}
+	if len(recent) >= cfg.RateLimit {
+		return &Violation{
+			Message:    "Too many runs, try again later!!",
+			RetryAfter: recent[len(recent)-1].Add(cfg.RateWindow).Sub(now),
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+)
+
+// submitLockClass namespaces the advisory locks taken per user.
+const submitLockClass = 1
+
+// Violation says which submission limit was hit and when to try again.
+type Violation struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+// CheckSubmission enforces the submission limits for a user who is about to
+// submit now. The submissions table is the record of past submissions, and
+// the per-user advisory lock held until tx ends makes the check and the
+// insert that follows atomic across every server instance. tx must be a
+// transaction.
+func CheckSubmission(tx *gorm.DB, cfg config.SubmitConfig, userID uint, problem *models.Problem, participation string, now time.Time) (*Violation, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", submitLockClass, int32(userID)).Error; err != nil {
+		return nil, err
+	}
+
+	if cfg.MinGap > 0 {
+		var last []time.Time
+		if err := tx.Model(&models.Submission{}).
+			Where("user_id = ? AND submitted_at > ?", userID, now.Add(-cfg.MinGap)).
+			Order("submitted_at DESC").
+			Limit(1).
+			Pluck("submitted_at", &last).Error; err != nil {
+			return nil, err
+		}
+		if len(last) > 0 {
+			return &Violation{
+				Message:    "You are submitting too fast, wait a few seconds!!",
+				RetryAfter: last[0].Add(cfg.MinGap).Sub(now),
+			}, nil
+		}
+	}
+
+	if cfg.ProblemLimit > 0 {
+		var recent []time.Time
+		if err := tx.Model(&models.Submission{}).
+			Where("user_id = ? AND problem_id = ? AND submitted_at > ?", userID, problem.ID, now.Add(-cfg.ProblemWindow)).
+			Order("submitted_at DESC").
+			Limit(cfg.ProblemLimit).
+			Pluck("submitted_at", &recent).Error; err != nil {
+			return nil, err
+		}
+		if len(recent) >= cfg.ProblemLimit {
+			return &Violation{
+				Message:    "Too many submissions for this problem, try again later!!",
+				RetryAfter: recent[len(recent)-1].Add(cfg.ProblemWindow).Sub(now),
+			}, nil
+		}
+	}
+
+	if cfg.ContestCap > 0 && participation == models.ParticipationContest {
+		var count int64
+		if err := tx.Model(&models.Submission{}).
+			Where("user_id = ? AND contest_id = ? AND participation_type = ?", userID, problem.ContestID, models.ParticipationContest).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count >= int64(cfg.ContestCap) {
+			// Practice submissions are not capped, so they open up when the
+			// contest ends.
+			return &Violation{
+				Message:    "You have used all your submissions for this contest!!",
+				RetryAfter: problem.Contest.EndTime.Sub(now),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,236 @@
+package rating
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TypePerformance moves a rating part of the way towards the contest
+// performance; every other rating type uses Elo against each opponent.
+const TypePerformance = "performance"
+
+type participant struct {
+	registration *models.UserContest
+	user         *models.User
+
+	rank        int
+	expected    float64 // opponents expected to be beaten
+	actual      float64 // opponents beaten, ties count half
+	performance int
+	newRating   int
+}
+
+// Calculate rates everyone on the final standings who registered, in the
+// order of their standings rank. It must run once per contest, after every
+// contest submission has been judged.
+func Calculate(tx *gorm.DB, contest *models.Contest) error {
+	if err := standings.Refresh(tx, contest); err != nil {
+		return err
+	}
+
+	// Only users with a contest submission get a rank, and disqualified users
+	// never do.
+	var registrations []models.UserContest
+	if err := tx.Where("contest_id = ? AND rank > 0", contest.ID).Find(&registrations).Error; err != nil {
+		return err
+	}
+	if len(registrations) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(registrations))
+	for _, registration := range registrations {
+		ids = append(ids, registration.UserID)
+	}
+	var users []models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	participants := make([]*participant, 0, len(registrations))
+	for i := range registrations {
+		if user, ok := byID[registrations[i].UserID]; ok {
+			participants = append(participants, &participant{registration: &registrations[i], user: user})
+		}
+	}
+	rate(participants, contest)
+
+	now := time.Now().UTC()
+	for _, p := range participants {
+		oldRating := p.user.CurrentRating
+		if err := tx.Create(&models.RatingChange{
+			UserID:      p.user.ID,
+			ContestID:   contest.ID,
+			OldRating:   oldRating,
+			NewRating:   p.newRating,
+			Rank:        p.rank,
+			Performance: p.performance,
+			ChangeTime:  now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.UserContest{}).
+			Where("user_id = ? AND contest_id = ?", p.user.ID, contest.ID).
+			Updates(map[string]interface{}{
+				"rating_change": p.newRating - oldRating,
+				"performance":   p.performance,
+				"expected_rank": float64(len(participants)) - p.expected,
+			}).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"current_rating": p.newRating,
+			"max_rating":     max(p.user.MaxRating, p.newRating),
+			"min_rating":     min(p.user.MinRating, p.newRating),
+			"total_contests": gorm.Expr("total_contests + 1"),
+		}
+		if p.rank == 1 {
+			updates["contests_won"] = gorm.Expr("contests_won + 1")
+		}
+		if err := tx.Model(p.user).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revert takes back the rating changes a contest gave to userIDs, as when
+// they are disqualified after the contest was rated. The change is
+// subtracted from the current rating, so ratings from later contests are
+// kept; the other participants keep their changes.
+func Revert(tx *gorm.DB, contest *models.Contest, userIDs []uint) error {
+	var changes []models.RatingChange
+	if err := tx.Where("contest_id = ? AND user_id IN ?", contest.ID, userIDs).Find(&changes).Error; err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, change.UserID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&change).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserContest{}).
+			Where("user_id = ? AND contest_id = ?", user.ID, contest.ID).
+			Updates(map[string]interface{}{"rating_change": 0, "performance": 0, "expected_rank": 0}).Error; err != nil {
+			return err
+		}
+
+		// The extremes are rebuilt from the contests that still count.
+		current := user.CurrentRating - (change.NewRating - change.OldRating)
+		var history []models.RatingChange
+		if err := tx.Where("user_id = ?", user.ID).Order("change_time, id").Find(&history).Error; err != nil {
+			return err
+		}
+		highest, lowest := current, current
+		if len(history) > 0 {
+			highest, lowest = history[0].OldRating, history[0].OldRating
+		}
+		for _, h := range history {
+			highest, lowest = max(highest, h.NewRating), min(lowest, h.NewRating)
+		}
+
+		updates := map[string]interface{}{
+			"current_rating": current,
+			"max_rating":     highest,
+			"min_rating":     lowest,
+			"total_contests": gorm.Expr("total_contests - 1"),
+		}
+		if change.Rank == 1 {
+			updates["contests_won"] = gorm.Expr("contests_won - 1")
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rate fills in the participants' new ratings from their standings ranks.
+func rate(participants []*participant, contest *models.Contest) {
+	sort.SliceStable(participants, func(i, j int) bool {
+		return participants[i].registration.Rank < participants[j].registration.Rank
+	})
+	for _, p := range participants {
+		p.rank = p.registration.Rank
+	}
+
+	ratings := make([]int, len(participants))
+	for i, p := range participants {
+		ratings[i] = p.user.CurrentRating
+	}
+
+	opponents := float64(len(participants) - 1)
+	for i, p := range participants {
+		for j, q := range participants {
+			if i == j {
+				continue
+			}
+			p.expected += winProbability(p.user.CurrentRating, q.user.CurrentRating)
+			switch {
+			case p.rank < q.rank:
+				p.actual++
+			case p.rank == q.rank:
+				p.actual += 0.5
+			}
+		}
+		p.newRating = p.user.CurrentRating
+		p.performance = p.user.CurrentRating
+		if opponents == 0 {
+			continue
+		}
+		p.performance = performance(ratings, i, p.actual)
+
+		k := float64(contest.RatingKFactor)
+		if contest.RatingType == TypePerformance {
+			p.newRating += int(math.Round(float64(p.performance-p.user.CurrentRating) * k / 100))
+		} else {
+			p.newRating += int(math.Round(k * (p.actual - p.expected) / opponents))
+		}
+	}
+}
+
+// winProbability is the Elo chance that a player rated a beats one rated b.
+func winProbability(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+// performance finds the rating at which beating actual opponents out of the
+// others is exactly what Elo would expect.
+func performance(ratings []int, self int, actual float64) int {
+	low, high := ratings[0], ratings[0]
+	for _, r := range ratings {
+		low, high = min(low, r), max(high, r)
+	}
+	low, high = low-800, high+800
+
+	for low < high {
+		mid := (low + high) / 2
+		var expected float64
+		for i, r := range ratings {
+			if i != self {
+				expected += winProbability(mid, r)
+			}
+		}
+		if expected < actual {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	return low
+}
@@ -0,0 +1,221 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/plagiarism"
+	"github.com/ankush-web-eng/contest-backend/queue"
+	"github.com/ankush-web-eng/contest-backend/rating"
+	"github.com/ankush-web-eng/contest-backend/standings"
+	"gorm.io/gorm"
+)
+
+// Hook runs inside the transaction that moves a contest to a new status. An
+// error rolls the transition back, and it is tried again on the next tick.
+type Hook func(tx *gorm.DB, contest *models.Contest) error
+
+var (
+	mu    sync.RWMutex
+	hooks = map[string][]Hook{}
+)
+
+// OnTransition registers a hook for every contest that reaches status.
+func OnTransition(status string, hook Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	hooks[status] = append(hooks[status], hook)
+}
+
+func init() {
+	OnTransition(models.ContestActive, startParticipations)
+	OnTransition(models.ContestCompleted, finishParticipations)
+}
+
+// Start moves contests through pending, active and completed as their start
+// and end times pass, refreshes the stored ranks of contests with newly
+// judged submissions, then rates completed contests once their submissions
+// are judged. Every instance may run it: transitions are conditional updates
+// that only one of them wins.
+func Start(cfg config.SchedulerConfig) {
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			tick(time.Now().UTC())
+			<-ticker.C
+		}
+	}()
+}
+
+func tick(now time.Time) {
+	// A contest created after its window passes through both in one tick.
+	advance(models.ContestPending, models.ContestActive, "start_time", now)
+	advance(models.ContestActive, models.ContestCompleted, "end_time", now)
+	refreshStandings()
+	rateCompleted(now)
+}
+
+// advance moves every contest in status from whose due column has passed.
+func advance(from, to, due string, now time.Time) {
+	var ids []uint
+	if err := config.GetDB().Model(&models.Contest{}).
+		Where("status = ? AND "+due+" <= ?", from, now).
+		Order("id").
+		Pluck("id", &ids).Error; err != nil {
+		log.Println("Failed to find", from, "contests:", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := transition(id, from, to); err != nil {
+			log.Println("Failed to move contest", id, "from", from, "to", to, ":", err)
+		}
+	}
+}
+
+func transition(id uint, from, to string) error {
+	return config.GetDB().Transaction(func(tx *gorm.DB) error {
+		// The row lock makes a second instance wait here and then match
+		// nothing, so hooks fire once.
+		res := tx.Model(&models.Contest{}).
+			Where("id = ? AND status = ?", id, from).
+			Update("status", to)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		var contest models.Contest
+		if err := tx.First(&contest, id).Error; err != nil {
+			return err
+		}
+
+		mu.RLock()
+		statusHooks := hooks[to]
+		mu.RUnlock()
+		for _, hook := range statusHooks {
+			if err := hook(tx, &contest); err != nil {
+				return err
+			}
+		}
+		log.Println("Contest", id, "is now", to)
+		return nil
+	})
+}
+
+// refreshStandings recomputes the stored ranks and public standings of every
+// contest whose results changed since its last refresh.
+func refreshStandings() {
+	db := config.GetDB()
+	var ids []uint
+	if err := db.Model(&models.Contest{}).
+		Where("standings_stale").
+		Order("id").
+		Pluck("id", &ids).Error; err != nil {
+		log.Println("Failed to find contests with stale standings:", err)
+		return
+	}
+
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Clearing the flag first means a submission judged while this
+			// runs sets it again, and is picked up on the next tick.
+			res := tx.Model(&models.Contest{}).
+				Where("id = ? AND standings_stale", id).
+				Update("standings_stale", false)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+
+			var contest models.Contest
+			if err := tx.First(&contest, id).Error; err != nil {
+				return err
+			}
+			return standings.Refresh(tx, &contest)
+		})
+		if err != nil {
+			log.Println("Failed to refresh the standings of contest", id, ":", err)
+		}
+	}
+}
+
+// rateCompleted calculates ratings for completed rated contests with no
+// contest submission left to judge. A contest with a freeze waits until its
+// final standings are revealed, so ratings cannot leak frozen results, and
+// one under plagiarism review waits until the review is closed, so cheaters
+// are disqualified before they are rated.
+func rateCompleted(now time.Time) {
+	db := config.GetDB()
+	pending := db.Model(&models.Submission{}).
+		Select("1").
+		Where("submissions.contest_id = contests.id AND participation_type = ? AND status IN ?",
+			models.ParticipationContest, []string{queue.StatusQueued, queue.StatusJudging})
+	reviewing := db.Model(&models.PlagiarismReport{}).
+		Select("1").
+		Where("plagiarism_reports.contest_id = contests.id").
+		Where("plagiarism_reports.status = ? OR EXISTS (?)", plagiarism.ReportRunning,
+			db.Model(&models.PlagiarismPair{}).Select("1").
+				Where("plagiarism_pairs.report_id = plagiarism_reports.id AND plagiarism_pairs.status = ?", plagiarism.PairPending))
+
+	var ids []uint
+	if err := db.Model(&models.Contest{}).
+		Where("status = ? AND is_rated AND rated_at IS NULL AND NOT EXISTS (?)", models.ContestCompleted, pending).
+		Where("freeze_minutes = 0 OR unfrozen_at IS NOT NULL").
+		Where("NOT EXISTS (?)", reviewing).
+		Order("id").
+		Pluck("id", &ids).Error; err != nil {
+		log.Println("Failed to find contests to rate:", err)
+		return
+	}
+
+	for _, id := range ids {
+		rated := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Model(&models.Contest{}).
+				Where("id = ? AND rated_at IS NULL", id).
+				Update("rated_at", now)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			rated = true
+
+			var contest models.Contest
+			if err := tx.First(&contest, id).Error; err != nil {
+				return err
+			}
+			return rating.Calculate(tx, &contest)
+		})
+		switch {
+		case err != nil:
+			log.Println("Failed to rate contest", id, ":", err)
+		case rated:
+			log.Println("Rated contest", id)
+		}
+	}
+}
+
+// startParticipations opens the contest to everyone registered. In a
+// windowed contest participants start their own windows instead.
+func startParticipations(tx *gorm.DB, contest *models.Contest) error {
+	if contest.MaxDuration > 0 {
+		return nil
+	}
+	return tx.Model(&models.UserContest{}).
+		Where("contest_id = ? AND status = ?", contest.ID, models.RegistrationRegistered).
+		Updates(map[string]interface{}{
+			"status":     models.RegistrationStarted,
+			"start_time": contest.StartTime,
+			"end_time":   contest.EndTime,
+		}).Error
+}
+
+// finishParticipations closes the contest to every participant still in it.
+func finishParticipations(tx *gorm.DB, contest *models.Contest) error {
+	return tx.Model(&models.UserContest{}).
+		Where("contest_id = ? AND status IN ?", contest.ID, []string{models.RegistrationRegistered, models.RegistrationStarted}).
+		Update("status", models.RegistrationFinished).Error
+}
@@ -0,0 +1,101 @@
+package standings
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refreshLockClass namespaces the advisory locks taken per contest.
+const refreshLockClass = 2
+
+// Refresh recomputes the official standings, frozen results included, and
+// stores every participant's score, penalty and rank on their UserContest.
+// It also stores the public standings of each cohort for Public to serve.
+// The per-contest advisory lock held until tx ends makes concurrent refreshes
+// run one after the other, each seeing what the previous one committed. tx
+// must be a transaction.
+func Refresh(tx *gorm.DB, contest *models.Contest) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", refreshLockClass, int32(contest.ID)).Error; err != nil {
+		return err
+	}
+
+	standings, err := Compute(tx, contest, CohortOfficial, false)
+	if err != nil {
+		return err
+	}
+
+	// Users who dropped off the standings, e.g. after a disqualification,
+	// keep no rank.
+	if err := tx.Model(&models.UserContest{}).
+		Where("contest_id = ?", contest.ID).
+		Updates(map[string]interface{}{"score": 0, "penalty": 0, "rank": 0}).Error; err != nil {
+		return err
+	}
+	for _, row := range standings.Rows {
+		if err := tx.Model(&models.UserContest{}).
+			Where("user_id = ? AND contest_id = ?", row.UserID, contest.ID).
+			Updates(map[string]interface{}{
+				"score":   row.Score,
+				"penalty": row.Penalty,
+				"rank":    row.Rank,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, cohort := range []string{CohortOfficial, CohortVirtual} {
+		board, err := Compute(tx, contest, cohort, true)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(board)
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.StandingsSnapshot{
+			ContestID: contest.ID,
+			Cohort:    cohort,
+			Board:     string(encoded),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Public returns the public standings of a cohort as the scheduler last
+// stored them, so they cost one read however many people watch. A contest
+// that was never refreshed is computed instead, and flagged so the next tick
+// stores it.
+func Public(db *gorm.DB, contest *models.Contest, cohort string) (*Standings, error) {
+	var snapshot models.StandingsSnapshot
+	err := db.Where("contest_id = ? AND cohort = ?", contest.ID, cohort).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := MarkStale(db, contest.ID); err != nil {
+			return nil, err
+		}
+		return Compute(db, contest, cohort, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var board Standings
+	if err := json.Unmarshal([]byte(snapshot.Board), &board); err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// MarkStale flags the contest's stored ranks and public standings as out of
+// date, for the scheduler to refresh. It only writes the contest row when the flag is not
+// set yet, so judging many submissions at once does not queue up on it.
+func MarkStale(tx *gorm.DB, contestID uint) error {
+	return tx.Model(&models.Contest{}).
+		Where("id = ? AND NOT standings_stale", contestID).
+		Update("standings_stale", true).Error
+}
@@ -0,0 +1,103 @@
+package standings
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFrozen      = errors.New("the standings are not frozen")
+	ErrContestRunning = errors.New("the contest has not ended yet")
+)
+
+// Reveal is one resolver step: a frozen cell shown with its real result.
+type Reveal struct {
+	UserID    uint
+	ProblemID uint
+	Cell      Cell
+	OldRank   int
+	NewRank   int
+}
+
+// Step reveals the next frozen cell the way an ICPC resolver does: the
+// leftmost frozen cell of the lowest ranked row that has one. Once nothing is
+// frozen it unfreezes the contest and returns a nil Reveal. Either way it
+// returns the public standings after the step. tx must be a transaction.
+func Step(tx *gorm.DB, contestID uint, now time.Time) (*Reveal, *Standings, error) {
+	// The row lock makes concurrent steps take turns.
+	var contest models.Contest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&contest, contestID).Error; err != nil {
+		return nil, nil, err
+	}
+	if !Frozen(&contest) {
+		return nil, nil, ErrNotFrozen
+	}
+	if now.Before(contest.EndTime) {
+		return nil, nil, ErrContestRunning
+	}
+
+	board, err := Compute(tx, &contest, CohortOfficial, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := len(board.Rows) - 1; i >= 0; i-- {
+		row := &board.Rows[i]
+		for _, cell := range row.Cells {
+			if cell.Frozen == 0 {
+				continue
+			}
+
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.StandingsReveal{
+				ContestID: contest.ID,
+				UserID:    row.UserID,
+				ProblemID: cell.ProblemID,
+			}).Error; err != nil {
+				return nil, nil, err
+			}
+			if err := MarkStale(tx, contest.ID); err != nil {
+				return nil, nil, err
+			}
+
+			after, err := Compute(tx, &contest, CohortOfficial, true)
+			if err != nil {
+				return nil, nil, err
+			}
+			reveal := &Reveal{UserID: row.UserID, ProblemID: cell.ProblemID, OldRank: row.Rank}
+			for _, r := range after.Rows {
+				if r.UserID != row.UserID {
+					continue
+				}
+				reveal.NewRank = r.Rank
+				for _, c := range r.Cells {
+					if c.ProblemID == cell.ProblemID {
+						reveal.Cell = c
+					}
+				}
+			}
+			return reveal, after, nil
+		}
+	}
+
+	if err := Unfreeze(tx, &contest, now); err != nil {
+		return nil, nil, err
+	}
+	board, err = Compute(tx, &contest, CohortOfficial, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, board, nil
+}
+
+// Unfreeze shows every result on the public standings.
+func Unfreeze(tx *gorm.DB, contest *models.Contest, now time.Time) error {
+	if err := tx.Model(contest).Update("unfrozen_at", now).Error; err != nil {
+		return err
+	}
+	contest.UnfrozenAt = &now
+	return MarkStale(tx, contest.ID)
+}
@@ -0,0 +1,343 @@
+package standings
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ModeICPC = "icpc" // problems solved, then penalty minutes
+	ModeIOI  = "ioi"  // sum of the best score on each problem
+)
+
+// Cohorts of a contest's standings. Virtual runs are ranked among
+// themselves.
+const (
+	CohortOfficial = "official"
+	CohortVirtual  = "virtual"
+)
+
+func ValidMode(mode string) bool {
+	return mode == "" || mode == ModeICPC || mode == ModeIOI
+}
+
+// Cell is one participant's result on one problem.
+type Cell struct {
+	ProblemID uint
+	Score     float64 // best score so far
+	Solved    bool
+	Attempts  int // judged wrong submissions, before the first AC when solved
+	Time      int // minutes from the participant's start to the first AC
+	Pending   int // submissions still being judged or hidden by the freeze
+	Frozen    int // submissions hidden by the freeze
+}
+
+type Row struct {
+	Rank                   int
+	UserID                 uint
+	VirtualParticipationID *uint // virtual cohort only
+	FirstName              string
+	LastName               string
+	Score                  float64 // problems solved in icpc mode, points in ioi mode
+	Penalty                int     // in minutes, icpc mode only
+	Cells                  []Cell
+}
+
+type Standings struct {
+	Mode     string
+	Cohort   string
+	Problems []uint
+	Rows     []Row
+}
+
+// entrant is someone on the standings: a user in the official cohort, a
+// virtual run in the virtual one.
+type entrant struct {
+	userID  uint
+	virtual *uint
+	start   time.Time
+	freeze  time.Time // official cohort only
+}
+
+type submission struct {
+	UserID                 uint
+	ProblemID              uint
+	VirtualParticipationID *uint
+	Status                 string
+	Score                  float64
+	SubmittedAt            time.Time
+}
+
+// Frozen reports whether the contest's public standings hide the results of
+// the last FreezeMinutes.
+func Frozen(contest *models.Contest) bool {
+	return contest.FreezeMinutes > 0 && contest.UnfrozenAt == nil
+}
+
+// freezeTime is when the public stops seeing the results of a participation
+// that ends at end: the last FreezeMinutes of the participant's own window.
+func freezeTime(contest *models.Contest, end time.Time) time.Time {
+	return end.Add(-time.Duration(contest.FreezeMinutes) * time.Minute)
+}
+
+// FreezeStarted reports whether the freeze has begun for anyone by now. In a
+// windowed contest the earliest windows end first.
+func FreezeStarted(contest *models.Contest, now time.Time) bool {
+	if !Frozen(contest) {
+		return false
+	}
+	end := contest.EndTime
+	if contest.MaxDuration > 0 {
+		end = earliest(end, contest.StartTime.Add(time.Duration(contest.MaxDuration)*time.Minute))
+	}
+	return !now.Before(freezeTime(contest, end))
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+type cellKey struct {
+	userID    uint
+	problemID uint
+}
+
+// Compute ranks a cohort of the contest from its submissions. The official
+// cohort is the registered users with a contest submission, disqualified
+// users left out. With hideFrozen, official submissions made after the
+// freeze of their participant's window show as pending unless the resolver
+// has revealed their cell.
+func Compute(db *gorm.DB, contest *models.Contest, cohort string, hideFrozen bool) (*Standings, error) {
+	standings := &Standings{Mode: mode(contest), Cohort: cohort, Problems: []uint{}, Rows: []Row{}}
+
+	if err := db.Model(&models.Problem{}).
+		Where("contest_id = ? AND is_published", contest.ID).
+		Order("id").
+		Pluck("id", &standings.Problems).Error; err != nil {
+		return nil, err
+	}
+
+	entrants, submissions, err := load(db, contest, cohort)
+	if err != nil {
+		return nil, err
+	}
+	if len(entrants) == 0 {
+		return standings, nil
+	}
+
+	users, err := names(db, entrants)
+	if err != nil {
+		return nil, err
+	}
+
+	column := make(map[uint]int, len(standings.Problems))
+	for i, id := range standings.Problems {
+		column[id] = i
+	}
+	rows := make(map[uint]*Row, len(entrants))
+	for key, e := range entrants {
+		user := users[e.userID]
+		rows[key] = &Row{
+			UserID:                 e.userID,
+			VirtualParticipationID: e.virtual,
+			FirstName:              user.FirstName,
+			LastName:               user.LastName,
+			Cells:                  newCells(standings.Problems),
+		}
+	}
+
+	hide := hideFrozen && cohort == CohortOfficial && Frozen(contest)
+	revealed := map[cellKey]bool{}
+	if hide {
+		var reveals []models.StandingsReveal
+		if err := db.Where("contest_id = ?", contest.ID).Find(&reveals).Error; err != nil {
+			return nil, err
+		}
+		for _, reveal := range reveals {
+			revealed[cellKey{reveal.UserID, reveal.ProblemID}] = true
+		}
+	}
+
+	for _, s := range submissions {
+		key := s.UserID
+		if s.VirtualParticipationID != nil {
+			key = *s.VirtualParticipationID
+		}
+		row, ok := rows[key]
+		i, published := column[s.ProblemID]
+		if !ok || !published {
+			continue
+		}
+		if hide && !s.SubmittedAt.Before(entrants[key].freeze) && !revealed[cellKey{s.UserID, s.ProblemID}] {
+			row.Cells[i].Pending++
+			row.Cells[i].Frozen++
+			continue
+		}
+		apply(&row.Cells[i], s, entrants[key].start)
+	}
+
+	for _, row := range rows {
+		total(row, standings.Mode, contest.PenaltyMinutes)
+		standings.Rows = append(standings.Rows, *row)
+	}
+	rank(standings.Rows, standings.Mode)
+	return standings, nil
+}
+
+func mode(contest *models.Contest) string {
+	if contest.ScoringMode == ModeICPC {
+		return ModeICPC
+	}
+	return ModeIOI
+}
+
+// load finds the cohort's entrants and their submissions, oldest first.
+func load(db *gorm.DB, contest *models.Contest, cohort string) (map[uint]*entrant, []submission, error) {
+	entrants := map[uint]*entrant{}
+	query := db.Model(&models.Submission{}).
+		Select("user_id, problem_id, virtual_participation_id, status, score, submitted_at").
+		Where("contest_id = ?", contest.ID).
+		Order("submitted_at, id")
+
+	if cohort == CohortVirtual {
+		var runs []models.VirtualParticipation
+		if err := db.Where("contest_id = ?", contest.ID).Find(&runs).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, run := range runs {
+			id := run.ID
+			entrants[run.ID] = &entrant{userID: run.UserID, virtual: &id, start: run.StartTime}
+		}
+		query = query.Where("participation_type = ?", models.ParticipationVirtual)
+	} else {
+		// Registered users who are not disqualified and made a contest
+		// submission.
+		submitted := db.Model(&models.Submission{}).
+			Select("1").
+			Where("submissions.user_id = user_contests.user_id AND submissions.contest_id = user_contests.contest_id AND participation_type = ?",
+				models.ParticipationContest)
+		var registrations []models.UserContest
+		if err := db.Where("contest_id = ? AND status <> ? AND EXISTS (?)", contest.ID, models.RegistrationDisqualified, submitted).
+			Find(&registrations).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, registration := range registrations {
+			start, end := contest.StartTime, contest.EndTime
+			if contest.MaxDuration > 0 && !registration.StartTime.IsZero() {
+				start, end = registration.StartTime, registration.EndTime
+			}
+			entrants[registration.UserID] = &entrant{userID: registration.UserID, start: start, freeze: freezeTime(contest, end)}
+		}
+		query = query.Where("participation_type = ?", models.ParticipationContest)
+	}
+
+	var submissions []submission
+	if err := query.Scan(&submissions).Error; err != nil {
+		return nil, nil, err
+	}
+	return entrants, submissions, nil
+}
+
+func names(db *gorm.DB, entrants map[uint]*entrant) (map[uint]models.User, error) {
+	ids := make([]uint, 0, len(entrants))
+	for _, e := range entrants {
+		ids = append(ids, e.userID)
+	}
+	var users []models.User
+	if err := db.Select("id, first_name, last_name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID, nil
+}
+
+func newCells(problems []uint) []Cell {
+	cells := make([]Cell, len(problems))
+	for i, id := range problems {
+		cells[i].ProblemID = id
+	}
+	return cells
+}
+
+// apply adds a submission, taken in submission order, to its cell. Compile
+// and judge errors are not the contestant's attempts, and nothing after the
+// first AC changes the ICPC result.
+func apply(cell *Cell, s submission, start time.Time) {
+	switch s.Status {
+	case judge.VerdictCompileError, judge.VerdictInternalError:
+		return
+	case judge.VerdictAccepted, judge.VerdictWrongAnswer, judge.VerdictTimeLimit, judge.VerdictMemoryLimit, judge.VerdictRuntimeError:
+	default:
+		cell.Pending++
+		return
+	}
+
+	cell.Score = max(cell.Score, s.Score)
+	if cell.Solved {
+		return
+	}
+	if s.Status == judge.VerdictAccepted {
+		cell.Solved = true
+		cell.Time = max(int(s.SubmittedAt.Sub(start)/time.Minute), 0)
+		return
+	}
+	cell.Attempts++
+}
+
+func total(row *Row, mode string, penaltyMinutes int) {
+	for _, cell := range row.Cells {
+		if mode == ModeIOI {
+			row.Score += cell.Score
+			continue
+		}
+		if cell.Solved {
+			row.Score++
+			row.Penalty += cell.Time + cell.Attempts*penaltyMinutes
+		}
+	}
+}
+
+// rank sorts the rows best first. Rows that tie on score, and on penalty in
+// ICPC mode, share a rank.
+func rank(rows []Row, mode string) {
+	tied := func(a, b *Row) bool {
+		return a.Score == b.Score && (mode == ModeIOI || a.Penalty == b.Penalty)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := &rows[i], &rows[j]
+		if !tied(a, b) {
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			return a.Penalty < b.Penalty
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return key(a) < key(b)
+	})
+
+	for i := range rows {
+		rows[i].Rank = i + 1
+		if i > 0 && tied(&rows[i], &rows[i-1]) {
+			rows[i].Rank = rows[i-1].Rank
+		}
+	}
+}
+
+func key(row *Row) uint {
+	if row.VirtualParticipationID != nil {
+		return *row.VirtualParticipationID
+	}
+	return 0
+}
@@ -0,0 +1,243 @@
+package standings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/models"
+)
+
+var start = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func at(minutes int, status string, score float64) submission {
+	return submission{Status: status, Score: score, SubmittedAt: start.Add(time.Duration(minutes) * time.Minute)}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		submissions []submission
+		want        Cell
+	}{
+		{
+			name:        "accepted first try",
+			submissions: []submission{at(17, judge.VerdictAccepted, 100)},
+			want:        Cell{Score: 100, Solved: true, Time: 17},
+		},
+		{
+			name: "wrong attempts before the first AC",
+			submissions: []submission{
+				at(5, judge.VerdictWrongAnswer, 0),
+				at(9, judge.VerdictTimeLimit, 0),
+				at(20, judge.VerdictAccepted, 100),
+			},
+			want: Cell{Score: 100, Solved: true, Attempts: 2, Time: 20},
+		},
+		{
+			name: "compile and judge errors are not attempts",
+			submissions: []submission{
+				at(1, judge.VerdictCompileError, 0),
+				at(2, judge.VerdictInternalError, 0),
+				at(3, judge.VerdictRuntimeError, 0),
+				at(4, judge.VerdictAccepted, 100),
+			},
+			want: Cell{Score: 100, Solved: true, Attempts: 1, Time: 4},
+		},
+		{
+			name: "nothing after the first AC counts",
+			submissions: []submission{
+				at(10, judge.VerdictAccepted, 100),
+				at(15, judge.VerdictWrongAnswer, 0),
+				at(30, judge.VerdictAccepted, 100),
+			},
+			want: Cell{Score: 100, Solved: true, Time: 10},
+		},
+		{
+			name: "unsolved keeps the best partial score",
+			submissions: []submission{
+				at(3, judge.VerdictWrongAnswer, 40),
+				at(8, judge.VerdictWrongAnswer, 70),
+				at(12, judge.VerdictMemoryLimit, 10),
+			},
+			want: Cell{Score: 70, Attempts: 3},
+		},
+		{
+			name: "later AC raises the score",
+			submissions: []submission{
+				at(3, judge.VerdictWrongAnswer, 40),
+				at(8, judge.VerdictAccepted, 100),
+			},
+			want: Cell{Score: 100, Solved: true, Attempts: 1, Time: 8},
+		},
+		{
+			name: "queued and judging are pending",
+			submissions: []submission{
+				at(3, "queued", 0),
+				at(4, "judging", 0),
+			},
+			want: Cell{Pending: 2},
+		},
+		{
+			name:        "submitted at the start",
+			submissions: []submission{at(0, judge.VerdictAccepted, 100)},
+			want:        Cell{Score: 100, Solved: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cell Cell
+			for _, s := range tt.submissions {
+				apply(&cell, s, start)
+			}
+			if cell != tt.want {
+				t.Errorf("cell = %+v, want %+v", cell, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotal(t *testing.T) {
+	cells := []Cell{
+		{Score: 100, Solved: true, Attempts: 2, Time: 30},
+		{Score: 40, Attempts: 3},
+		{Score: 100, Solved: true, Time: 50},
+	}
+
+	tests := []struct {
+		mode    string
+		penalty int
+		score   float64
+		minutes int
+	}{
+		// Wrong attempts on an unsolved problem cost nothing.
+		{ModeICPC, 20, 2, 30 + 2*20 + 50},
+		{ModeICPC, 0, 2, 30 + 50},
+		{ModeIOI, 20, 240, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			row := Row{Cells: cells}
+			total(&row, tt.mode, tt.penalty)
+			if row.Score != tt.score || row.Penalty != tt.minutes {
+				t.Errorf("score %v penalty %d, want %v and %d", row.Score, row.Penalty, tt.score, tt.minutes)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	type entry struct {
+		user    uint
+		score   float64
+		penalty int
+	}
+	type placed struct {
+		user uint
+		rank int
+	}
+
+	tests := []struct {
+		name string
+		mode string
+		rows []entry
+		want []placed
+	}{
+		{
+			name: "icpc by solved then penalty",
+			mode: ModeICPC,
+			rows: []entry{{1, 2, 100}, {2, 3, 300}, {3, 2, 80}, {4, 0, 0}},
+			want: []placed{{2, 1}, {3, 2}, {1, 3}, {4, 4}},
+		},
+		{
+			name: "icpc ties need equal penalty",
+			mode: ModeICPC,
+			rows: []entry{{5, 2, 100}, {2, 2, 100}, {9, 2, 101}, {1, 3, 400}},
+			want: []placed{{1, 1}, {2, 2}, {5, 2}, {9, 4}},
+		},
+		{
+			name: "ioi ignores penalty",
+			mode: ModeIOI,
+			rows: []entry{{3, 150, 10}, {1, 150, 99}, {2, 200, 500}, {4, 50, 0}},
+			want: []placed{{2, 1}, {1, 2}, {3, 2}, {4, 4}},
+		},
+		{
+			name: "everyone tied",
+			mode: ModeIOI,
+			rows: []entry{{3, 0, 0}, {2, 0, 0}, {1, 0, 0}},
+			want: []placed{{1, 1}, {2, 1}, {3, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make([]Row, 0, len(tt.rows))
+			for _, e := range tt.rows {
+				rows = append(rows, Row{UserID: e.user, Score: e.score, Penalty: e.penalty})
+			}
+			rank(rows, tt.mode)
+
+			for i, want := range tt.want {
+				if rows[i].UserID != want.user || rows[i].Rank != want.rank {
+					t.Errorf("position %d: user %d rank %d, want user %d rank %d", i, rows[i].UserID, rows[i].Rank, want.user, want.rank)
+				}
+			}
+		})
+	}
+}
+
+func TestRankVirtualRuns(t *testing.T) {
+	id := func(v uint) *uint { return &v }
+	rows := []Row{
+		{UserID: 1, VirtualParticipationID: id(8), Score: 100},
+		{UserID: 1, VirtualParticipationID: id(3), Score: 100},
+		{UserID: 2, VirtualParticipationID: id(5), Score: 300},
+	}
+	rank(rows, ModeIOI)
+
+	wantRuns := []uint{5, 3, 8}
+	wantRanks := []int{1, 2, 2}
+	for i := range rows {
+		if *rows[i].VirtualParticipationID != wantRuns[i] || rows[i].Rank != wantRanks[i] {
+			t.Errorf("position %d: run %d rank %d, want run %d rank %d",
+				i, *rows[i].VirtualParticipationID, rows[i].Rank, wantRuns[i], wantRanks[i])
+		}
+	}
+}
+
+func TestFreezeStarted(t *testing.T) {
+	unfrozen := start.Add(3 * time.Hour)
+	contest := func(maxDuration int, unfrozenAt *time.Time) *models.Contest {
+		return &models.Contest{
+			StartTime:     start,
+			EndTime:       start.Add(2 * time.Hour),
+			MaxDuration:   maxDuration,
+			FreezeMinutes: 30,
+			UnfrozenAt:    unfrozenAt,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		contest *models.Contest
+		minutes int
+		want    bool
+	}{
+		{"before the freeze", contest(0, nil), 89, false},
+		{"at the freeze", contest(0, nil), 90, true},
+		{"earliest window freezes first", contest(60, nil), 30, true},
+		{"before the earliest window freezes", contest(60, nil), 29, false},
+		{"window longer than the contest", contest(180, nil), 89, false},
+		{"unfrozen", contest(0, &unfrozen), 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FreezeStarted(tt.contest, start.Add(time.Duration(tt.minutes)*time.Minute)); got != tt.want {
+				t.Errorf("FreezeStarted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/config"
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+)
+
+// Rebuild recomputes every problem and user counter from the submissions
+// table. Submissions are locked against writes meanwhile, so verdicts that
+// come in during the rebuild wait and are then counted on top of it.
+func Rebuild() error {
+	return config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE submissions IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		// Anything with a final verdict counts, including submissions that
+		// were judged before counting existed. Rows being rejudged keep the
+		// flag they had.
+		if err := tx.Model(&models.Submission{}).
+			Where("status IN ? AND stats_counted = ?", countedVerdicts, false).
+			Update("stats_counted", true).Error; err != nil {
+			return err
+		}
+
+		if err := rebuildProblems(tx); err != nil {
+			return err
+		}
+		return rebuildUsers(tx)
+	})
+}
+
+func rebuildProblems(tx *gorm.DB) error {
+	if err := tx.Exec(`UPDATE problems SET total_submissions = 0, successful_submissions = 0`).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`
+		UPDATE problems SET total_submissions = s.total, successful_submissions = s.accepted
+		FROM (
+			SELECT problem_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE status = ?) AS accepted
+			FROM submissions WHERE stats_counted GROUP BY problem_id
+		) AS s
+		WHERE problems.id = s.problem_id`, judge.VerdictAccepted).Error
+}
+
+func rebuildUsers(tx *gorm.DB) error {
+	var totals []struct {
+		UserID uint
+		Total  int
+	}
+	if err := tx.Model(&models.Submission{}).
+		Select("user_id, COUNT(*) AS total").
+		Where("stats_counted").
+		Group("user_id").
+		Scan(&totals).Error; err != nil {
+		return err
+	}
+
+	// The first accepted submission of each user on each problem.
+	var solves []struct {
+		UserID   uint
+		SolvedAt time.Time
+	}
+	if err := tx.Model(&models.Submission{}).
+		Select("user_id, MIN(submitted_at) AS solved_at").
+		Where("stats_counted AND status = ?", judge.VerdictAccepted).
+		Group("user_id, problem_id").
+		Scan(&solves).Error; err != nil {
+		return err
+	}
+
+	solvedAt := map[uint][]time.Time{}
+	for _, solve := range solves {
+		solvedAt[solve.UserID] = append(solvedAt[solve.UserID], solve.SolvedAt)
+	}
+
+	if err := tx.Exec(`UPDATE users SET total_submissions = 0, total_solved = 0, current_streak = 0, max_streak = 0`).Error; err != nil {
+		return err
+	}
+
+	for _, total := range totals {
+		times := solvedAt[total.UserID]
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		var current, best int
+		var last time.Time
+		for _, t := range times {
+			current, best, last = advanceStreak(current, best, last, t)
+		}
+
+		updates := map[string]interface{}{
+			"total_submissions": total.Total,
+			"total_solved":      len(times),
+			"current_streak":    current,
+			"max_streak":        best,
+		}
+		if !last.IsZero() {
+			updates["last_problem_solved"] = last
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", total.UserID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
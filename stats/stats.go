@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/judge"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// countedVerdicts are the verdicts that count as a submission. IE means the
+// judge failed, not the contestant, so it is left out until a rejudge.
+var countedVerdicts = []string{
+	judge.VerdictAccepted,
+	judge.VerdictWrongAnswer,
+	judge.VerdictTimeLimit,
+	judge.VerdictMemoryLimit,
+	judge.VerdictRuntimeError,
+	judge.VerdictCompileError,
+}
+
+func counted(verdict string) bool {
+	for _, v := range countedVerdicts {
+		if v == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+// Record updates the problem and user counters for a submission that just
+// got its final verdict. Each submission is counted once, the first time it
+// gets such a verdict; a rejudge that changes it is reconciled by Rebuild.
+// The user row is locked so concurrent verdicts for one user agree on
+// whether a problem was already solved.
+func Record(tx *gorm.DB, submission *models.Submission, verdict string) error {
+	if !counted(verdict) {
+		return nil
+	}
+
+	res := tx.Model(&models.Submission{}).
+		Where("id = ? AND stats_counted = ?", submission.ID, false).
+		Update("stats_counted", true)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	accepted := 0
+	if verdict == judge.VerdictAccepted {
+		accepted = 1
+	}
+	if err := tx.Model(&models.Problem{}).Where("id = ?", submission.ProblemID).UpdateColumns(map[string]interface{}{
+		"total_submissions":      gorm.Expr("total_submissions + 1"),
+		"successful_submissions": gorm.Expr("successful_submissions + ?", accepted),
+	}).Error; err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "current_streak", "max_streak", "last_problem_solved").
+		First(&user, submission.UserID).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"total_submissions": gorm.Expr("total_submissions + 1"),
+	}
+
+	if accepted == 1 {
+		var earlier int64
+		if err := tx.Model(&models.Submission{}).
+			Where("user_id = ? AND problem_id = ? AND id <> ? AND status = ? AND stats_counted = ?",
+				submission.UserID, submission.ProblemID, submission.ID, judge.VerdictAccepted, true).
+			Count(&earlier).Error; err != nil {
+			return err
+		}
+		if earlier == 0 {
+			current, best, last := advanceStreak(user.CurrentStreak, user.MaxStreak, user.LastProblemSolved, submission.SubmittedAt)
+			updates["total_solved"] = gorm.Expr("total_solved + 1")
+			updates["current_streak"] = current
+			updates["max_streak"] = best
+			updates["last_problem_solved"] = last
+		}
+	}
+
+	return tx.Model(&models.User{}).Where("id = ?", submission.UserID).UpdateColumns(updates).Error
+}
+
+// Retract takes the submissions in ids back out of the counters, so their
+// next verdicts are counted afresh by Record. A user loses a solve only when
+// no other counted submission of theirs solved the problem. Streaks are not
+// rolled back; Rebuild recomputes them.
+func Retract(tx *gorm.DB, ids []uint) error {
+	var submissions []models.Submission
+	if err := tx.Select("id", "user_id", "problem_id", "status").
+		Where("id IN ? AND stats_counted = ?", ids, true).
+		Find(&submissions).Error; err != nil {
+		return err
+	}
+	if len(submissions) == 0 {
+		return nil
+	}
+
+	retracted := make([]uint, 0, len(submissions))
+	for _, submission := range submissions {
+		retracted = append(retracted, submission.ID)
+	}
+	if err := tx.Model(&models.Submission{}).Where("id IN ?", retracted).Update("stats_counted", false).Error; err != nil {
+		return err
+	}
+
+	type solve struct{ userID, problemID uint }
+	problems := map[uint][2]int{} // total and accepted
+	users := map[uint]int{}
+	solves := map[solve]bool{}
+	for _, submission := range submissions {
+		counts := problems[submission.ProblemID]
+		counts[0]++
+		if submission.Status == judge.VerdictAccepted {
+			counts[1]++
+			solves[solve{submission.UserID, submission.ProblemID}] = true
+		}
+		problems[submission.ProblemID] = counts
+		users[submission.UserID]++
+	}
+
+	for problemID, counts := range problems {
+		if err := tx.Model(&models.Problem{}).Where("id = ?", problemID).UpdateColumns(map[string]interface{}{
+			"total_submissions":      gorm.Expr("total_submissions - ?", counts[0]),
+			"successful_submissions": gorm.Expr("successful_submissions - ?", counts[1]),
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	lost := map[uint]int{}
+	for s := range solves {
+		var remaining int64
+		if err := tx.Model(&models.Submission{}).
+			Where("user_id = ? AND problem_id = ? AND status = ? AND stats_counted = ?",
+				s.userID, s.problemID, judge.VerdictAccepted, true).
+			Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			lost[s.userID]++
+		}
+	}
+
+	for userID, total := range users {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+			"total_submissions": gorm.Expr("total_submissions - ?", total),
+			"total_solved":      gorm.Expr("total_solved - ?", lost[userID]),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advanceStreak counts consecutive UTC days with at least one new problem
+// solved.
+func advanceStreak(current, best int, last, solvedAt time.Time) (int, int, time.Time) {
+	lastDay, day := utcDay(last), utcDay(solvedAt)
+	switch {
+	case last.IsZero():
+		current = 1
+	case day.Equal(lastDay) || day.Before(lastDay):
+		// Same day, or a delayed verdict for an earlier one.
+		current = max(current, 1)
+	case day.Equal(lastDay.AddDate(0, 0, 1)):
+		current++
+	default:
+		current = 1
+	}
+	if solvedAt.After(last) {
+		last = solvedAt
+	}
+	return current, max(best, current), last
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
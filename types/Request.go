@@ -25,7 +25,7 @@ type CreateContestRequest struct {
 	EndTime     string `json:"end_time" binding:"required"`
 
 	IsPublic    bool `json:"is_public" binding:"required"`
-	MaxDuration int  `json:"max_duration"`
+	MaxDuration int  `json:"max_duration"` // in minutes, 0 for no personal windows
 
 	CreatorID uint `json:"creator_id" binding:"required"`
 
@@ -33,9 +33,18 @@ type CreateContestRequest struct {
 	RatingFloor int    `json:"rating_floor"`
 	RatingCeil  int    `json:"rating_ceil"`
 
+	RegistrationDeadline string `json:"registration_deadline"` // RFC3339, empty for the start time
+	MaxParticipants      int    `json:"max_participants"`
+
 	IsRated       bool   `json:"is_rated" binding:"required"`
 	RatingType    string `json:"rating_type" binding:"required"`
 	RatingKFactor int    `json:"rating_k_factor" binding:"required"`
+
+	StopPolicy string `json:"stop_policy"`
+
+	ScoringMode    string `json:"scoring_mode"`    // icpc or ioi, empty for ioi
+	PenaltyMinutes *int   `json:"penalty_minutes"` // nil for 20
+	FreezeMinutes  int    `json:"freeze_minutes"`  // 0 for no freeze
 }
 
 type UpdateContestRequest struct {
@@ -54,11 +63,32 @@ type UpdateContestRequest struct {
 		SampleOutput   string `json:"sample_output"`
 		TestCasesCount int    `json:"test_cases_count"`
 
+		CheckerMode     string  `json:"checker_mode"`
+		CheckerAbsEps   float64 `json:"checker_abs_eps"`
+		CheckerRelEps   float64 `json:"checker_rel_eps"`
+		CheckerSource   string  `json:"checker_source"`
+		CheckerLanguage string  `json:"checker_language"`
+
+		InteractorSource   string `json:"interactor_source"`
+		InteractorLanguage string `json:"interactor_language"`
+
+		StopPolicy string `json:"stop_policy"`
+
+		TestGroups []struct {
+			Name          string   `json:"name"`
+			Points        float64  `json:"points"`
+			ScoringPolicy string   `json:"scoring_policy"`
+			Dependencies  []string `json:"dependencies"` // names of other groups
+		} `json:"test_groups"`
+
+		ReferenceSolutions []ReferenceSolutionRequest `json:"reference_solutions"`
+
 		TestCases []struct {
 			ProblemID uint   `json:"problem_id"`
 			Input     string `json:"input"`
 			Output    string `json:"output"`
 			IsHidden  bool   `json:"is_hidden"`
+			Group     string `json:"group"`
 		} `json:"test_cases"`
 	} `json:"problems"`
 	ContestId uint `json:"contest_id"`
@@ -71,3 +101,50 @@ type SubmitCodeRequest struct {
 	Language  string `json:"language" binding:"required"`
 	Code      string `json:"code" binding:"required"`
 }
+
+type RunCodeRequest struct {
+	ProblemID uint    `json:"problem_id" binding:"required"`
+	Language  string  `json:"language" binding:"required"`
+	Code      string  `json:"code" binding:"required"`
+	Input     *string `json:"input"` // the problem's sample input when omitted
+}
+
+type ReferenceSolutionRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Language        string `json:"language" binding:"required"`
+	Source          string `json:"source" binding:"required"`
+	ExpectedVerdict string `json:"expected_verdict" binding:"required"` // AC, WA or TLE
+}
+
+type LanguageRequest struct {
+	Slug           string `json:"slug" binding:"required"`
+	Name           string `json:"name" binding:"required"`
+	Version        string `json:"version"`
+	JudgeID        int    `json:"judge_id"`
+	SourceFile     string `json:"source_file"`
+	CompileCommand string `json:"compile_command"`
+	RunCommand     string `json:"run_command"`
+	Enabled        bool   `json:"enabled"`
+
+	TimeMultiplier float64 `json:"time_multiplier"` // 0 for 1
+	TimeOffset     int     `json:"time_offset"`     // in milliseconds
+	MemoryOffset   int     `json:"memory_offset"`   // in MB
+}
+
+// ProblemLanguageLimitRequest overrides a language's limits for one problem.
+// Nil fields keep the language's own value.
+type ProblemLanguageLimitRequest struct {
+	Language       string   `json:"language" binding:"required"`
+	TimeMultiplier *float64 `json:"time_multiplier"`
+	TimeOffset     *int     `json:"time_offset"`
+	MemoryOffset   *int     `json:"memory_offset"`
+}
+
+type PlagiarismCheckRequest struct {
+	Threshold float64 `json:"threshold"` // 0 for the default, otherwise in (0, 1]
+}
+
+type PlagiarismReviewRequest struct {
+	Status     string `json:"status" binding:"required"` // confirmed or dismissed
+	Disqualify []uint `json:"disqualify"`                // users of a confirmed pair to disqualify
+}
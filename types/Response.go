@@ -0,0 +1,324 @@
+package types
+
+import (
+	"time"
+
+	"github.com/ankush-web-eng/contest-backend/languages"
+	"github.com/ankush-web-eng/contest-backend/models"
+	"github.com/ankush-web-eng/contest-backend/standings"
+)
+
+// Responses keep the field names the models serialize with, so clients see
+// the same shape whether or not they are admins; they only lose the fields
+// and rows a contestant must not see.
+
+type ContestResponse struct {
+	ID          uint
+	Name        string
+	Description string
+	StartTime   time.Time
+	EndTime     time.Time
+
+	IsPublic    bool
+	MaxDuration int
+	CreatorID   uint
+
+	Status      string
+	RatingFloor int
+	RatingCeil  int
+
+	RegistrationDeadline *time.Time
+	MaxParticipants      int
+
+	IsRated    bool
+	RatingType string
+
+	ScoringMode    string
+	PenaltyMinutes int
+	FreezeMinutes  int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Problems []ProblemResponse
+}
+
+type ProblemResponse struct {
+	ID          uint
+	ContestID   uint
+	Title       string
+	Description string
+
+	TimeLimit   int
+	MemoryLimit int
+	Difficulty  string
+	Score       int
+	Rating      int
+
+	SampleInput    string
+	SampleOutput   string
+	TestCasesCount int
+
+	CheckerMode string
+	Interactive bool
+
+	// Zero while SubmissionsHidden, during the freeze.
+	TotalSubmissions      int
+	SuccessfulSubmissions int
+	SubmissionsHidden     bool
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	TestCases      []TestCaseResponse
+	TestGroups     []TestGroupResponse
+	LanguageLimits []LanguageLimitResponse
+	Submissions    []models.Submission
+}
+
+// LanguageLimitResponse is the effective limits of a problem in one language.
+type LanguageLimitResponse struct {
+	Language    string
+	Name        string
+	TimeLimit   int // in milliseconds
+	MemoryLimit int // in MB
+}
+
+type TestCaseResponse struct {
+	ID          uint
+	ProblemID   uint
+	Input       string
+	Output      string
+	TimeLimit   int
+	MemoryLimit int
+	GroupID     *uint
+}
+
+type SubmissionResponse struct {
+	ID        uint
+	UserID    uint
+	ProblemID uint
+	ContestID uint
+	Language  string
+	Code      string
+	Status    string
+	Score     float64
+	Runtime   int
+	Memory    int
+
+	ParticipationType      string
+	VirtualParticipationID *uint
+
+	CompileOutput string
+	TestResults   []SubmissionTestResultResponse
+	History       []models.SubmissionVerdictHistory
+
+	SubmittedAt time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type SubmissionTestResultResponse struct {
+	ID             uint
+	TestCaseID     uint
+	Hidden         bool
+	Verdict        string
+	Time           int
+	WallTime       int
+	Memory         int
+	ExitCode       int
+	Signal         int
+	Stdout         string
+	Stderr         string
+	CheckerMessage string
+}
+
+type TestGroupResponse struct {
+	ID            uint
+	Name          string
+	Points        float64
+	ScoringPolicy string
+}
+
+// NewContestResponse lists only published problems, and none until the
+// contest starts. catalog is the set of languages to show the problems'
+// limits for.
+func NewContestResponse(contest models.Contest, userID uint, catalog []models.Language) ContestResponse {
+	frozen := standings.FreezeStarted(&contest, time.Now())
+	response := ContestResponse{
+		ID:                   contest.ID,
+		Name:                 contest.Name,
+		Description:          contest.Description,
+		StartTime:            contest.StartTime,
+		EndTime:              contest.EndTime,
+		IsPublic:             contest.IsPublic,
+		MaxDuration:          contest.MaxDuration,
+		CreatorID:            contest.CreatorID,
+		Status:               contest.Status,
+		RatingFloor:          contest.RatingFloor,
+		RatingCeil:           contest.RatingCeil,
+		RegistrationDeadline: contest.RegistrationDeadline,
+		MaxParticipants:      contest.MaxParticipants,
+		IsRated:              contest.IsRated,
+		RatingType:           contest.RatingType,
+		CreatedAt:            contest.CreatedAt,
+		UpdatedAt:            contest.UpdatedAt,
+		Problems:             make([]ProblemResponse, 0, len(contest.Problems)),
+	}
+	if contest.Status == models.ContestPending {
+		return response
+	}
+	for _, problem := range contest.Problems {
+		if problem.IsPublished {
+			response.Problems = append(response.Problems, NewProblemResponse(problem, userID, catalog, frozen))
+		}
+	}
+	return response
+}
+
+// NewProblemResponse drops hidden test cases, the checker and interactor
+// sources, and submissions that do not belong to userID. When frozen the
+// submission counts are hidden too, since they would give away the results
+// the frozen standings keep back. The problem's LanguageLimits must be
+// loaded for its overrides to show.
+func NewProblemResponse(problem models.Problem, userID uint, catalog []models.Language, frozen bool) ProblemResponse {
+	response := ProblemResponse{
+		ID:                    problem.ID,
+		ContestID:             problem.ContestID,
+		Title:                 problem.Title,
+		Description:           problem.Description,
+		TimeLimit:             problem.TimeLimit,
+		MemoryLimit:           problem.MemoryLimit,
+		Difficulty:            problem.Difficulty,
+		Score:                 problem.Score,
+		Rating:                problem.Rating,
+		SampleInput:           problem.SampleInput,
+		SampleOutput:          problem.SampleOutput,
+		TestCasesCount:        problem.TestCasesCount,
+		CheckerMode:           problem.CheckerMode,
+		Interactive:           problem.InteractorSource != "",
+		TotalSubmissions:      problem.TotalSubmissions,
+		SuccessfulSubmissions: problem.SuccessfulSubmissions,
+		CreatedAt:             problem.CreatedAt,
+		UpdatedAt:             problem.UpdatedAt,
+		TestCases:             []TestCaseResponse{},
+		TestGroups:            make([]TestGroupResponse, 0, len(problem.TestGroups)),
+		LanguageLimits:        NewLanguageLimitResponses(&problem, catalog),
+		Submissions:           []models.Submission{},
+	}
+	if frozen {
+		response.TotalSubmissions, response.SuccessfulSubmissions = 0, 0
+		response.SubmissionsHidden = true
+	}
+
+	for _, testCase := range problem.TestCases {
+		if testCase.IsHidden {
+			continue
+		}
+		response.TestCases = append(response.TestCases, TestCaseResponse{
+			ID:          testCase.ID,
+			ProblemID:   testCase.ProblemID,
+			Input:       testCase.Input,
+			Output:      testCase.Output,
+			TimeLimit:   testCase.TimeLimit,
+			MemoryLimit: testCase.MemoryLimit,
+			GroupID:     testCase.GroupID,
+		})
+	}
+
+	for _, group := range problem.TestGroups {
+		response.TestGroups = append(response.TestGroups, TestGroupResponse{
+			ID:            group.ID,
+			Name:          group.Name,
+			Points:        group.Points,
+			ScoringPolicy: group.ScoringPolicy,
+		})
+	}
+
+	for _, submission := range problem.Submissions {
+		if submission.UserID == userID {
+			response.Submissions = append(response.Submissions, submission)
+		}
+	}
+	return response
+}
+
+// NewLanguageLimitResponses applies the problem's overrides to every
+// language in catalog.
+func NewLanguageLimitResponses(problem *models.Problem, catalog []models.Language) []LanguageLimitResponse {
+	overrides := make(map[string]*models.ProblemLanguageLimit, len(problem.LanguageLimits))
+	for i := range problem.LanguageLimits {
+		overrides[problem.LanguageLimits[i].Language] = &problem.LanguageLimits[i]
+	}
+
+	responses := make([]LanguageLimitResponse, 0, len(catalog))
+	for i := range catalog {
+		limits := languages.EffectiveLimits(problem, &catalog[i], overrides[catalog[i].Slug])
+		responses = append(responses, LanguageLimitResponse{
+			Language:    catalog[i].Slug,
+			Name:        catalog[i].Name,
+			TimeLimit:   limits.TimeLimit,
+			MemoryLimit: limits.MemoryLimit,
+		})
+	}
+	return responses
+}
+
+// NewSubmissionResponse shows only the verdict, time and memory of the tests
+// in hidden, so their output and checker messages cannot reveal the data.
+// The submission's TestResults and History must be loaded.
+func NewSubmissionResponse(submission models.Submission, hidden []uint) SubmissionResponse {
+	isHidden := make(map[uint]bool, len(hidden))
+	for _, id := range hidden {
+		isHidden[id] = true
+	}
+
+	response := SubmissionResponse{
+		ID:                     submission.ID,
+		UserID:                 submission.UserID,
+		ProblemID:              submission.ProblemID,
+		ContestID:              submission.ContestID,
+		Language:               submission.Language,
+		Code:                   submission.Code,
+		Status:                 submission.Status,
+		Score:                  submission.Score,
+		Runtime:                submission.Runtime,
+		Memory:                 submission.Memory,
+		ParticipationType:      submission.ParticipationType,
+		VirtualParticipationID: submission.VirtualParticipationID,
+		CompileOutput:          submission.CompileOutput,
+		TestResults:            make([]SubmissionTestResultResponse, 0, len(submission.TestResults)),
+		History:                submission.History,
+		SubmittedAt:            submission.SubmittedAt,
+		CreatedAt:              submission.CreatedAt,
+		UpdatedAt:              submission.UpdatedAt,
+	}
+
+	for _, result := range submission.TestResults {
+		if isHidden[result.TestCaseID] {
+			response.TestResults = append(response.TestResults, SubmissionTestResultResponse{
+				ID:         result.ID,
+				TestCaseID: result.TestCaseID,
+				Hidden:     true,
+				Verdict:    result.Verdict,
+				Time:       result.Time,
+				Memory:     result.Memory,
+			})
+			continue
+		}
+		response.TestResults = append(response.TestResults, SubmissionTestResultResponse{
+			ID:             result.ID,
+			TestCaseID:     result.TestCaseID,
+			Verdict:        result.Verdict,
+			Time:           result.Time,
+			WallTime:       result.WallTime,
+			Memory:         result.Memory,
+			ExitCode:       result.ExitCode,
+			Signal:         result.Signal,
+			Stdout:         result.Stdout,
+			Stderr:         result.Stderr,
+			CheckerMessage: result.CheckerMessage,
+		})
+	}
+	return response
+}